@@ -0,0 +1,287 @@
+package config
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"laundry-scheduler/models"
+)
+
+// Config holds runtime settings read from the environment
+type Config struct {
+	// TransitTimeout enables the washer-to-dryer transit state when non-zero
+	TransitTimeout time.Duration
+	// MaxLoadDuration caps how long any single load may run
+	MaxLoadDuration time.Duration
+	// DisableAutoRemove keeps completed items until staff clear them
+	DisableAutoRemove bool
+	// IdleAlertAfter alerts staff when the machine sits free this long while people wait
+	IdleAlertAfter time.Duration
+	// AbsentAfter flags the front of the line as possibly absent after the machine sits free this long
+	AbsentAfter time.Duration
+	// AutoSkipAbsent lets the next person go ahead of someone flagged as possibly absent
+	AutoSkipAbsent bool
+	// RequeuePriority lets someone requeueing on the day their load finished go to the front of the line
+	RequeuePriority bool
+	// TimelineRetention is how far back the queue's past state can be replayed; 0 disables replay
+	TimelineRetention time.Duration
+	// HistorySize is how many completed loads /api/json/history returns; 0 disables it
+	HistorySize int
+	// StateFile is where the queue is saved so it survives restarts; empty keeps it in memory only
+	StateFile string
+	// StartGap is how long after the most recent start another should begin, to stagger finishes
+	StartGap time.Duration
+	// RejectBunchedStarts refuses starts inside StartGap instead of only suggesting a delay
+	RejectBunchedStarts bool
+	// Machines is how many washers share the queue
+	Machines int
+	// LoadEstimateMinutes is how long a load without a timer is assumed to take in wait estimates
+	LoadEstimateMinutes int
+	// UrgencySoonMinutes and UrgencyFinishingMinutes are the remaining minutes
+	// at or below which a running load is shown as "soon" or "finishing"
+	UrgencySoonMinutes      int
+	UrgencyFinishingMinutes int
+	// SlowRequestAfter logs requests taking at least this long and lists them for staff; 0 disables it
+	SlowRequestAfter time.Duration
+	// AdminToken authorizes staff endpoints; they are disabled when empty
+	AdminToken string `secret:"true"`
+	// Allowlist restricts who may join the queue; empty allows everyone
+	Allowlist []string
+	// DefaultNumLoads is used when the add form omits num_loads; 0 makes it required
+	DefaultNumLoads int
+	// AutoStartMinutes starts a timer of this length when someone joins an
+	// empty queue without giving a duration; 0 just queues them
+	AutoStartMinutes int
+	// CollapseCompleted shows finished loads as a count the queue view can expand
+	CollapseCompleted bool
+	// DurationPresets maps cycle names such as "normal" to minutes
+	DurationPresets map[string]int
+	// TLSCertFile and TLSKeyFile serve the site over HTTPS when both are set
+	TLSCertFile string
+	TLSKeyFile  string
+	// RedirectAddr is where plain HTTP is redirected to HTTPS, such as ":80";
+	// empty disables the redirect. It only applies with TLS.
+	RedirectAddr string
+}
+
+// Load reads the configuration from the environment, using defaults for unset
+// values. Setting only one of TLS_CERT_FILE and TLS_KEY_FILE is fatal rather
+// than silently serving plain HTTP. Auto-start and preset durations longer
+// than MAX_LOAD_DURATION are cut down to it, as such timers would be refused.
+func Load() *Config {
+	cfg := &Config{
+		TransitTimeout:          getDuration("TRANSIT_TIMEOUT", 0),
+		MaxLoadDuration:         getDuration("MAX_LOAD_DURATION", 3*time.Hour),
+		DisableAutoRemove:       getBool("DISABLE_AUTO_REMOVE", false),
+		IdleAlertAfter:          getDuration("IDLE_ALERT_AFTER", 0),
+		AbsentAfter:             getDuration("ABSENT_AFTER", 0),
+		AutoSkipAbsent:          getBool("AUTO_SKIP_ABSENT", false),
+		RequeuePriority:         getBool("REQUEUE_PRIORITY", false),
+		TimelineRetention:       getDuration("TIMELINE_RETENTION", 24*time.Hour),
+		HistorySize:             getInt("HISTORY_SIZE", 50, 0, 1000),
+		StateFile:               os.Getenv("STATE_FILE"),
+		StartGap:                getDuration("START_GAP", 0),
+		RejectBunchedStarts:     getBool("REJECT_BUNCHED_STARTS", false),
+		Machines:                getInt("MACHINES", 1, 1, 20),
+		LoadEstimateMinutes:     getInt("LOAD_ESTIMATE_MINUTES", models.DefaultLoadMinutes, 1, 24*60),
+		UrgencySoonMinutes:      getInt("URGENCY_SOON_MINUTES", models.DefaultUrgencySoonMinutes, 1, 24*60),
+		UrgencyFinishingMinutes: getInt("URGENCY_FINISHING_MINUTES", models.DefaultUrgencyFinishingMinutes, 1, 24*60),
+		SlowRequestAfter:        getDuration("SLOW_REQUEST_THRESHOLD", 500*time.Millisecond),
+		AdminToken:              os.Getenv("ADMIN_TOKEN"),
+		Allowlist:               loadAllowlist(os.Getenv("ALLOWLIST_FILE")),
+		DefaultNumLoads:         getInt("DEFAULT_NUM_LOADS", 0, 0, 10),
+		AutoStartMinutes:        getInt("AUTO_START_MINUTES", 0, 0, 24*60),
+		CollapseCompleted:       getBool("COLLAPSE_COMPLETED", false),
+		DurationPresets:         getPresets("DURATION_PRESETS", "quick=30,normal=45,heavy=60"),
+		TLSCertFile:             os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:              os.Getenv("TLS_KEY_FILE"),
+		RedirectAddr:            os.Getenv("HTTP_REDIRECT_ADDR"),
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		log.Fatal("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	cfg.clampToMaxLoad()
+	return cfg
+}
+
+// clampToMaxLoad cuts AutoStartMinutes and every duration preset down to
+// MaxLoadDuration, logging each one it changes. A zero MaxLoadDuration means
+// no cap.
+func (c *Config) clampToMaxLoad() {
+	if c.MaxLoadDuration <= 0 {
+		return
+	}
+	max := int(c.MaxLoadDuration / time.Minute)
+	if c.AutoStartMinutes > max {
+		log.Printf("Warning: AUTO_START_MINUTES %d is longer than MAX_LOAD_DURATION, using %d", c.AutoStartMinutes, max)
+		c.AutoStartMinutes = max
+	}
+	for name, minutes := range c.DurationPresets {
+		if minutes > max {
+			log.Printf("Warning: DURATION_PRESETS %s=%d is longer than MAX_LOAD_DURATION, using %d", name, minutes, max)
+			c.DurationPresets[name] = max
+		}
+	}
+}
+
+// TLSEnabled reports whether the site is served over HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// ResolvePreset returns the minutes for a named duration preset (case-insensitive)
+func (c *Config) ResolvePreset(name string) (int, bool) {
+	minutes, ok := c.DurationPresets[strings.ToLower(strings.TrimSpace(name))]
+	return minutes, ok
+}
+
+// Redacted replaces secret values in Sanitized output
+const Redacted = "***"
+
+// Sanitized returns the effective settings keyed by field name, safe to show
+// support staff: any field tagged secret:"true" is replaced with Redacted
+// when set, and durations are shown in their readable form
+func (c *Config) Sanitized() map[string]interface{} {
+	result := make(map[string]interface{})
+	v := reflect.ValueOf(*c)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		switch {
+		case field.Tag.Get("secret") == "true":
+			if value.IsZero() {
+				result[field.Name] = ""
+			} else {
+				result[field.Name] = Redacted
+			}
+		case field.Type == reflect.TypeOf(time.Duration(0)):
+			result[field.Name] = value.Interface().(time.Duration).String()
+		default:
+			result[field.Name] = value.Interface()
+		}
+	}
+	return result
+}
+
+// IsAllowed reports whether name may join the queue. Names are matched
+// case-insensitively, and everyone is allowed when the allowlist is empty.
+func (c *Config) IsAllowed(name string) bool {
+	return models.IsAllowed(c.Allowlist, name)
+}
+
+// QueueOptions returns the queue options described by the config
+func (c *Config) QueueOptions() models.Options {
+	return models.Options{
+		TransitTimeout:          c.TransitTimeout,
+		MaxLoadDuration:         c.MaxLoadDuration,
+		DisableAutoRemove:       c.DisableAutoRemove,
+		IdleAlertAfter:          c.IdleAlertAfter,
+		AbsentAfter:             c.AbsentAfter,
+		AutoSkipAbsent:          c.AutoSkipAbsent,
+		RequeuePriority:         c.RequeuePriority,
+		TimelineRetention:       c.TimelineRetention,
+		HistorySize:             c.HistorySize,
+		StatePath:               c.StateFile,
+		StartGap:                c.StartGap,
+		RejectBunchedStarts:     c.RejectBunchedStarts,
+		Machines:                c.Machines,
+		LoadMinutes:             c.LoadEstimateMinutes,
+		Allowlist:               c.Allowlist,
+		UrgencySoonMinutes:      c.UrgencySoonMinutes,
+		UrgencyFinishingMinutes: c.UrgencyFinishingMinutes,
+	}
+}
+
+// loadAllowlist reads one name per line from path, skipping blank lines and
+// "#" comments. An unreadable file is fatal rather than silently allowing everyone.
+func loadAllowlist(path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Error reading allowlist: %v", err)
+	}
+
+	names := make([]string, 0)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			names = append(names, line)
+		}
+	}
+	return names
+}
+
+// getDuration reads a duration such as "15m" from the environment
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("Warning: invalid %s %q, using default %v", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// getBool reads a boolean such as "true" or "1" from the environment
+func getBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using default %v", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+// getInt reads an integer within [min, max] from the environment
+func getInt(key string, fallback, min, max int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		log.Printf("Warning: invalid %s %q, using default %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// getPresets reads comma-separated name=minutes pairs such as "quick=30,normal=45"
+func getPresets(key, fallback string) map[string]int {
+	parse := func(value string) (map[string]int, bool) {
+		presets := make(map[string]int)
+		for _, pair := range strings.Split(value, ",") {
+			name, minutesStr, ok := strings.Cut(pair, "=")
+			minutes, err := strconv.Atoi(strings.TrimSpace(minutesStr))
+			name = strings.ToLower(strings.TrimSpace(name))
+			if !ok || err != nil || minutes <= 0 || name == "" {
+				return nil, false
+			}
+			presets[name] = minutes
+		}
+		return presets, true
+	}
+
+	if value := os.Getenv(key); value != "" {
+		if presets, ok := parse(value); ok {
+			return presets
+		}
+		log.Printf("Warning: invalid %s %q, using default %q", key, value, fallback)
+	}
+	presets, _ := parse(fallback)
+	return presets
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSanitizedRedactsSecrets(t *testing.T) {
+	cfg := &Config{AdminToken: "hunter2", MaxLoadDuration: 90 * time.Minute, Machines: 3}
+	sanitized := cfg.Sanitized()
+
+	if got := sanitized["AdminToken"]; got != Redacted {
+		t.Errorf("AdminToken = %v, want %q", got, Redacted)
+	}
+	if got := sanitized["MaxLoadDuration"]; got != "1h30m0s" {
+		t.Errorf("MaxLoadDuration = %v, want 1h30m0s", got)
+	}
+	if got := sanitized["Machines"]; got != 3 {
+		t.Errorf("Machines = %v, want 3", got)
+	}
+
+	if got := (&Config{}).Sanitized()["AdminToken"]; got != "" {
+		t.Errorf("unset AdminToken = %v, want empty so it reads as unset", got)
+	}
+}
+
+func TestTLSEnabledNeedsCertAndKey(t *testing.T) {
+	tests := []struct {
+		cert, key string
+		want      bool
+	}{
+		{"", "", false},
+		{"cert.pem", "", false},
+		{"", "key.pem", false},
+		{"cert.pem", "key.pem", true},
+	}
+	for _, tt := range tests {
+		cfg := &Config{TLSCertFile: tt.cert, TLSKeyFile: tt.key}
+		if got := cfg.TLSEnabled(); got != tt.want {
+			t.Errorf("cert %q, key %q: TLSEnabled = %v, want %v", tt.cert, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestDurationsAreClampedToMaxLoad(t *testing.T) {
+	cfg := &Config{
+		MaxLoadDuration:  40 * time.Minute,
+		AutoStartMinutes: 60,
+		DurationPresets:  map[string]int{"quick": 30, "heavy": 60},
+	}
+	cfg.clampToMaxLoad()
+
+	if cfg.AutoStartMinutes != 40 {
+		t.Errorf("AutoStartMinutes = %d, want 40", cfg.AutoStartMinutes)
+	}
+	if quick, heavy := cfg.DurationPresets["quick"], cfg.DurationPresets["heavy"]; quick != 30 || heavy != 40 {
+		t.Errorf("presets quick=%d heavy=%d, want 30 and 40", quick, heavy)
+	}
+
+	uncapped := &Config{AutoStartMinutes: 600}
+	uncapped.clampToMaxLoad()
+	if uncapped.AutoStartMinutes != 600 {
+		t.Errorf("with no cap AutoStartMinutes = %d, want 600", uncapped.AutoStartMinutes)
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"laundry-scheduler/models"
+)
+
+// RequireAdmin wraps a handler so it only runs for requests carrying the admin
+// token as a bearer token. Admin endpoints are disabled when token is empty.
+func RequireAdmin(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.Error(w, "Admin access is not configured", http.StatusForbidden)
+			return
+		}
+
+		if !isAdmin(token, r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// isAdmin reports whether r carries token as a bearer token. It is always
+// false when token is empty, as admin access is then disabled.
+func isAdmin(token string, r *http.Request) bool {
+	if token == "" {
+		return false
+	}
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// CompleteExpired finishes all in-progress loads whose timers have run out
+// and notifies everyone who is now next up
+func (h *APIHandler) CompleteExpired(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	completed, announced := h.queue.CompleteExpiredAndNotify()
+	items := make([]*models.QueueItem, len(announced))
+	for i := range announced {
+		items[i] = &announced[i]
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Completed int            `json:"completed"`
+		NextUp    []QueueItemDTO `json:"next_up"`
+	}{completed, newQueueItemDTOs(h.queue, h.queue.GetAll(), items)})
+}
+
+// PauseAll freezes every countdown in the room, such as in an emergency
+func (h *APIHandler) PauseAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.queue.PauseAll()
+	writeJSON(w, http.StatusOK, struct {
+		Paused bool `json:"paused"`
+	}{true})
+}
+
+// ResumeAll restarts every countdown frozen by PauseAll
+func (h *APIHandler) ResumeAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.queue.ResumeAll()
+	writeJSON(w, http.StatusOK, struct {
+		Paused bool `json:"paused"`
+	}{false})
+}
+
+// RemoveByName removes every queue entry for the "name" query parameter
+func (h *APIHandler) RemoveByName(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Removed int `json:"removed"`
+	}{h.queue.RemoveByName(name)})
+}
+
+// GetConfig returns the effective configuration with secrets redacted
+func (h *APIHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.config.Sanitized())
+}
@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"laundry-scheduler/config"
+	"laundry-scheduler/models"
+)
+
+const (
+	// DefaultForecastMinutes is how far ahead the forecast looks by default
+	DefaultForecastMinutes = 60
+	// MaxForecastMinutes is the furthest ahead a forecast may look
+	MaxForecastMinutes = 24 * 60
+	// MaxSMSLength is the longest status text that fits in a single SMS
+	MaxSMSLength = 160
+	// DefaultRecentlyFreedWindow is how far back recently freed machines are listed by default
+	DefaultRecentlyFreedWindow = 10 * time.Minute
+	// DefaultExpiringSoonWindow is how far ahead items about to be auto-removed are listed by default
+	DefaultExpiringSoonWindow = time.Minute
+	// MaxRequestBytes limits the size of a JSON request body
+	MaxRequestBytes = 1 << 10
+	// MaxBatchIDs is the most items one batch lookup may ask for
+	MaxBatchIDs = 50
+)
+
+// APIHandler handles JSON requests for the laundry queue application
+type APIHandler struct {
+	queue  *models.LaundryQueue
+	config *config.Config
+}
+
+// NewAPIHandler creates a new JSON API handler
+func NewAPIHandler(queue *models.LaundryQueue, cfg *config.Config) *APIHandler {
+	return &APIHandler{queue: queue, config: cfg}
+}
+
+// writeJSON encodes data as JSON with common error handling
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("JSON encode error: %v", err)
+	}
+}
+
+// YouView describes the requesting user's own item in the queue
+type YouView struct {
+	ID         string `json:"id"`
+	Status     string `json:"status"`
+	Position   int    `json:"position,omitempty"`
+	ETAMinutes int    `json:"eta_minutes"`
+}
+
+// GetQueue returns the current queue as JSON. The "status" query parameter
+// limits the list to a comma-separated set of statuses. When the "me" query
+// parameter names an item, a "you" object with its position and ETA is included.
+// Responses carry Last-Modified, and If-Modified-Since is answered with 304
+// when the queue hasn't changed since, unless an item is still counting down.
+// Waiting and running items always count down, so in practice a 304 only
+// comes while the queue is empty or, without auto-removal, all done.
+func (h *APIHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// A bad request is refused even when the queue hasn't changed
+	var statuses []string
+	if statusParam := r.URL.Query().Get("status"); statusParam != "" {
+		statuses = strings.Split(statusParam, ",")
+		for _, status := range statuses {
+			if !models.IsValidStatus(status) {
+				http.Error(w, fmt.Sprintf("Unknown status %q", status), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	modified := h.queue.LastModified()
+	items := h.queue.GetAll()
+	if !modified.IsZero() && !hasCountdowns(h.queue, items) {
+		modified = modified.Truncate(time.Second)
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	listed := items
+	if statuses != nil {
+		listed = models.FilterByStatus(items, statuses...)
+	}
+
+	var you *YouView
+	if me := r.URL.Query().Get("me"); me != "" {
+		for _, item := range items {
+			if item.ID == me {
+				you = &YouView{
+					ID:         item.ID,
+					Status:     item.Status,
+					Position:   models.WaitingPositions(items, h.queue.TierWeights())[item.ID],
+					ETAMinutes: models.ETAMinutes(items, item, h.queue.TierWeights(), h.queue.Machines(), h.queue.LoadMinutes(), time.Now()),
+				}
+				break
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Items     []QueueItemDTO    `json:"items"`
+		Positions []models.Position `json:"positions"`
+		You       *YouView          `json:"you"`
+	}{newQueueItemDTOs(h.queue, items, listed), models.SortedPositions(items, h.queue.TierWeights()), you})
+}
+
+// hasCountdowns reports whether any item's JSON changes with the clock alone,
+// such as a running load's remaining minutes, a waiting item's ETA or a
+// completed item's auto-removal, so a 304 would leave clients with stale values
+func hasCountdowns(queue *models.LaundryQueue, items []*models.QueueItem) bool {
+	for _, item := range items {
+		if item.Status != models.StatusCompleted || queue.AutoRemoveEnabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBatch returns the items named by the comma-separated "ids" query
+// parameter from one snapshot of the queue, listing IDs that matched nothing
+// under "missing", so a client can follow several people in one request
+func (h *APIHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ids := make([]string, 0)
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		http.Error(w, "At least one id is required", http.StatusBadRequest)
+		return
+	}
+	if len(ids) > MaxBatchIDs {
+		http.Error(w, fmt.Sprintf("Too many ids (at most %d)", MaxBatchIDs), http.StatusBadRequest)
+		return
+	}
+
+	items := h.queue.GetAll()
+	found, missing := models.FindByIDs(items, ids)
+	writeJSON(w, http.StatusOK, struct {
+		Items   []QueueItemDTO `json:"items"`
+		Missing []string       `json:"missing"`
+	}{newQueueItemDTOs(h.queue, items, found), missing})
+}
+
+// GetForecast returns the projected queue state a number of minutes ahead
+func (h *APIHandler) GetForecast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	minutes := DefaultForecastMinutes
+	if minutesStr := r.URL.Query().Get("minutes"); minutesStr != "" {
+		var err error
+		minutes, err = strconv.Atoi(minutesStr)
+		if err != nil || minutes < 0 || minutes > MaxForecastMinutes {
+			http.Error(w, fmt.Sprintf("Invalid minutes (must be 0-%d)", MaxForecastMinutes), http.StatusBadRequest)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, newForecastDTO(h.queue.Forecast(time.Duration(minutes)*time.Minute)))
+}
+
+// GetSummary returns item counts by state and peak queue depth
+func (h *APIHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.queue.Summary())
+}
+
+// GetNextUp returns the person whose turn is next, or null if nobody is waiting
+func (h *APIHandler) GetNextUp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var next *QueueItemDTO
+	if item := h.queue.NextUp(); item != nil {
+		next = &newQueueItemDTOs(h.queue, h.queue.GetAll(), []*models.QueueItem{item})[0]
+	}
+	writeJSON(w, http.StatusOK, next)
+}
+
+// StartTimer starts the timer for a waiting item from a JSON body such as
+// {"duration": 45} and returns the updated item. It responds 404 for an
+// unknown item and 400 for an invalid duration or an item that isn't waiting.
+func (h *APIHandler) StartTimer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Path[len("/api/json/queue/start/"):]
+	var body struct {
+		Duration int `json:"duration"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBytes)).Decode(&body); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := checkDuration(body.Duration, h.queue.MaxLoadMinutes()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.queue.StartTimer(id, body.Duration); err != nil {
+		_, message := startErrorResponse(err)
+		status := http.StatusBadRequest
+		if errors.Is(err, models.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, message, status)
+		return
+	}
+
+	all := h.queue.GetAll()
+	for _, item := range all {
+		if item.ID == id {
+			writeJSON(w, http.StatusOK, newQueueItemDTOs(h.queue, all, []*models.QueueItem{item})[0])
+			return
+		}
+	}
+	// Removed between starting and reading back
+	http.Error(w, "Item not found", http.StatusNotFound)
+}
+
+// GetCanStart reports whether the "name" query parameter could start a load
+// right now, and if not why, so a client can check before trying
+func (h *APIHandler) GetCanStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	ok, reason := h.queue.CanStart(name)
+	writeJSON(w, http.StatusOK, struct {
+		CanStart bool   `json:"can_start"`
+		Reason   string `json:"reason,omitempty"`
+	}{ok, reason})
+}
+
+// GetWaitEstimate returns how long someone joining the queue now would wait
+func (h *APIHandler) GetWaitEstimate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		WaitMinutes int `json:"wait_minutes"`
+	}{h.queue.WaitIfJoinedNow()})
+}
+
+// GetQueueText returns a one-line plain text status suitable for an SMS reply
+func (h *APIHandler) GetQueueText(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	summary := h.queue.Summary()
+	text := fmt.Sprintf("%d running, %d waiting, ", summary.Running, summary.Waiting)
+	if minutes := h.queue.NextFreeMinutes(); minutes > 0 {
+		text += fmt.Sprintf("next free ~%d min.", minutes)
+	} else {
+		text += "machine free now."
+	}
+	if len(text) > MaxSMSLength {
+		text = text[:MaxSMSLength]
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, text)
+}
+
+// GetAddedBetween returns the items queued between the RFC3339 "from" and "to"
+// query parameters, defaulting to the start of today through now
+func (h *APIHandler) GetAddedBetween(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to := now
+
+	query := r.URL.Query()
+	if fromStr := query.Get("from"); fromStr != "" {
+		t, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			http.Error(w, "Invalid from time (must be RFC3339)", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if toStr := query.Get("to"); toStr != "" {
+		t, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			http.Error(w, "Invalid to time (must be RFC3339)", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	items := h.queue.AddedBetween(from, to)
+	writeJSON(w, http.StatusOK, newQueueItemDTOs(h.queue, h.queue.GetAll(), items))
+}
+
+// GetStateAt replays the queue as it was at the RFC3339 "time" query parameter
+func (h *APIHandler) GetStateAt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("time"))
+	if err != nil {
+		http.Error(w, "Invalid time (must be RFC3339)", http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.queue.StateAt(at)
+	switch {
+	case errors.Is(err, models.ErrNoTimeline):
+		http.Error(w, "Queue history is not enabled", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrBeforeTimeline):
+		http.Error(w, "Queue history does not go back that far", http.StatusBadRequest)
+		return
+	}
+
+	snapshots := make([]*models.QueueItem, len(items))
+	for i := range items {
+		snapshots[i] = &items[i]
+	}
+	writeJSON(w, http.StatusOK, struct {
+		At    time.Time      `json:"at"`
+		Items []QueueItemDTO `json:"items"`
+	}{at, newSnapshotDTOs(snapshots)})
+}
+
+// GetHistory lists the most recently completed loads, newest first
+func (h *APIHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newSnapshotDTOs(h.queue.GetHistory()))
+}
+
+// GetRecentlyFreed lists washer loads that finished within the "within" window (e.g. "10m")
+func (h *APIHandler) GetRecentlyFreed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	within := DefaultRecentlyFreedWindow
+	if withinStr := r.URL.Query().Get("within"); withinStr != "" {
+		d, err := time.ParseDuration(withinStr)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid within duration", http.StatusBadRequest)
+			return
+		}
+		within = d
+	}
+
+	writeJSON(w, http.StatusOK, h.queue.RecentlyFreed(within))
+}
+
+// GetExpiringSoon lists completed items that will be auto-removed within the
+// "within" window (e.g. "1m"), so a display can warn their owners
+func (h *APIHandler) GetExpiringSoon(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	within := DefaultExpiringSoonWindow
+	if withinStr := r.URL.Query().Get("within"); withinStr != "" {
+		d, err := time.ParseDuration(withinStr)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid within duration", http.StatusBadRequest)
+			return
+		}
+		within = d
+	}
+
+	writeJSON(w, http.StatusOK, newQueueItemDTOs(h.queue, h.queue.GetAll(), h.queue.ExpiringSoon(within)))
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"laundry-scheduler/config"
+	"laundry-scheduler/models"
+)
+
+// getQueueSince requests the queue with If-Modified-Since set to since
+func getQueueSince(api *APIHandler, since time.Time) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/queue", nil)
+	req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+	rec := httptest.NewRecorder()
+	api.GetQueue(rec, req)
+	return rec
+}
+
+func TestGetQueueNotModifiedOnlyWithoutCountdowns(t *testing.T) {
+	queue, _, api := newTestHandlers(t, &config.Config{DisableAutoRemove: true})
+	later := time.Now().Add(time.Hour)
+
+	item, err := queue.AddAndStart("Runner", 30, 1, models.TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := getQueueSince(api, later); rec.Code != http.StatusOK {
+		t.Errorf("running load: status %d, want 200 so the countdown moves", rec.Code)
+	}
+
+	queue.CompleteNow(item.ID)
+	if rec := getQueueSince(api, later); rec.Code != http.StatusNotModified {
+		t.Errorf("only completed loads: status %d, want 304", rec.Code)
+	}
+}
+
+func TestGetQueueRejectsUnknownStatusWhenNotModified(t *testing.T) {
+	queue, _, api := newTestHandlers(t, &config.Config{DisableAutoRemove: true})
+	item, err := queue.AddAndStart("Runner", 30, 1, models.TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.CompleteNow(item.ID)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/queue?status=bogus", nil)
+	req.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	rec := httptest.NewRecorder()
+	api.GetQueue(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown status on an unchanged queue: status %d, want 400", rec.Code)
+	}
+}
+
+func TestGetQueueIfModifiedSince(t *testing.T) {
+	queue, _, api := newTestHandlers(t, &config.Config{DisableAutoRemove: true})
+	item, err := queue.AddAndStart("Runner", 30, 1, models.TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.CompleteNow(item.ID)
+	changed := queue.LastModified()
+
+	rec := getQueueSince(api, changed.Add(time.Minute))
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("since after the last change: status %d, want 304", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("304 response has a body: %q", rec.Body.String())
+	}
+
+	rec = getQueueSince(api, changed.Add(-time.Minute))
+	if rec.Code != http.StatusOK {
+		t.Errorf("since before the last change: status %d, want 200", rec.Code)
+	}
+	lastModified, err := http.ParseTime(rec.Header().Get("Last-Modified"))
+	if err != nil {
+		t.Fatalf("Last-Modified: %v", err)
+	}
+	if !lastModified.Equal(changed.Truncate(time.Second)) {
+		t.Errorf("Last-Modified %s, want %s", lastModified, changed.UTC())
+	}
+}
+
+func TestGetHistoryListsCompletedLoads(t *testing.T) {
+	queue, _, api := newTestHandlers(t, &config.Config{HistorySize: 5})
+	item, err := queue.AddAndStart("Runner", 30, 1, models.TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.CompleteNow(item.ID)
+
+	rec := httptest.NewRecorder()
+	api.GetHistory(rec, httptest.NewRequest(http.MethodGet, "/api/json/history", nil))
+	var history []QueueItemDTO
+	if err := json.NewDecoder(rec.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].ID != item.ID || history[0].Status != models.StatusCompleted {
+		t.Errorf("history = %+v, want the completed load", history)
+	}
+}
+
+func TestGetQueueYouMatchesPosition(t *testing.T) {
+	queue, _, api := newTestHandlers(t, &config.Config{})
+	if _, err := queue.AddAndStart("Runner", 30, 1, models.TierResident); err != nil {
+		t.Fatal(err)
+	}
+	queue.AddToQueue("Ann", 1, models.TierResident)
+	me := queue.AddToQueue("Bob", 1, models.TierResident)
+
+	rec := httptest.NewRecorder()
+	api.GetQueue(rec, httptest.NewRequest(http.MethodGet, "/api/json/queue?me="+me.ID, nil))
+	var body struct {
+		Positions []models.Position `json:"positions"`
+		You       *YouView          `json:"you"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.You == nil || body.You.ID != me.ID || body.You.Status != models.StatusWaiting {
+		t.Fatalf("you = %+v, want Bob's waiting item", body.You)
+	}
+	for _, position := range body.Positions {
+		if position.ID == me.ID && position.Position != body.You.Position {
+			t.Errorf("you.position = %d, positions list has %d", body.You.Position, position.Position)
+		}
+	}
+	if body.You.Position != 2 {
+		t.Errorf("you.position = %d, want 2", body.You.Position)
+	}
+}
+
+func TestGetQueueTextFormat(t *testing.T) {
+	queue, _, api := newTestHandlers(t, &config.Config{})
+	text := func() string {
+		rec := httptest.NewRecorder()
+		api.GetQueueText(rec, httptest.NewRequest(http.MethodGet, "/api/queue/text", nil))
+		if len(rec.Body.String()) > MaxSMSLength {
+			t.Errorf("text is %d bytes, over the %d byte SMS limit", rec.Body.Len(), MaxSMSLength)
+		}
+		return rec.Body.String()
+	}
+
+	if got, want := text(), "0 running, 0 waiting, machine free now."; got != want {
+		t.Errorf("empty queue text %q, want %q", got, want)
+	}
+	if _, err := queue.AddAndStart("Runner", 30, 1, models.TierResident); err != nil {
+		t.Fatal(err)
+	}
+	queue.AddToQueue("Ann", 1, models.TierResident)
+	// Remaining minutes are rounded down, so the 30 minute load shows 29
+	if got, want := text(), "1 running, 1 waiting, next free ~29 min."; got != want {
+		t.Errorf("busy queue text %q, want %q", got, want)
+	}
+}
+
+func TestGetQueueStatusFilter(t *testing.T) {
+	queue, _, api := newTestHandlers(t, &config.Config{})
+	if _, err := queue.AddAndStart("Runner", 30, 1, models.TierResident); err != nil {
+		t.Fatal(err)
+	}
+	queue.AddToQueue("Ann", 1, models.TierResident)
+	queue.AddToQueue("Bob", 1, models.TierResident)
+
+	tests := []struct {
+		status string
+		code   int
+		count  int
+	}{
+		{"waiting", http.StatusOK, 2},
+		{"in_progress", http.StatusOK, 1},
+		{"waiting,in_progress", http.StatusOK, 3},
+		{"completed", http.StatusOK, 0},
+		{"lost", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		api.GetQueue(rec, httptest.NewRequest(http.MethodGet, "/api/json/queue?status="+tt.status, nil))
+		if rec.Code != tt.code {
+			t.Errorf("status=%s: code %d, want %d", tt.status, rec.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var body struct {
+			Items []QueueItemDTO `json:"items"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Items) != tt.count {
+			t.Errorf("status=%s: %d items, want %d", tt.status, len(body.Items), tt.count)
+		}
+	}
+}
+
+func TestGetNextUpRespectsTierAndIsNullWhenEmpty(t *testing.T) {
+	queue, _, api := newTestHandlers(t, &config.Config{})
+	getNext := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		api.GetNextUp(rec, httptest.NewRequest(http.MethodGet, "/api/queue/next", nil))
+		return rec
+	}
+
+	if body := strings.TrimSpace(getNext().Body.String()); body != "null" {
+		t.Errorf("empty queue: body %q, want null", body)
+	}
+
+	if _, err := queue.AddAndStart("Runner", 30, 1, models.TierResident); err != nil {
+		t.Fatal(err)
+	}
+	queue.AddToQueue("Guest", 1, models.TierGuest)
+	queue.AddToQueue("Resident", 1, models.TierResident)
+	queue.AddToQueue("Staff", 1, models.TierStaff)
+
+	var next QueueItemDTO
+	if err := json.NewDecoder(getNext().Body).Decode(&next); err != nil {
+		t.Fatal(err)
+	}
+	if next.Name != "Staff" {
+		t.Errorf("next up %q, want Staff ahead of earlier residents and guests", next.Name)
+	}
+}
+
+func TestMetadataRoundTripsThroughAddAndQueue(t *testing.T) {
+	queue, web, api := newTestHandlers(t, &config.Config{})
+	if _, err := queue.AddAndStart("Runner", 30, 1, models.TierResident); err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"name": {"Ann"}, "num_loads": {"1"}, "meta.room": {"12B"}, "meta.building": {"North"}}
+	if rec := postForm(web.AddToQueue, "/api/queue/add", form, nil); rec.Code != http.StatusOK {
+		t.Fatalf("add: status %d", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	api.GetQueue(rec, httptest.NewRequest(http.MethodGet, "/api/json/queue", nil))
+	var body struct {
+		Items []QueueItemDTO `json:"items"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	var ann *QueueItemDTO
+	for i := range body.Items {
+		if body.Items[i].Name == "Ann" {
+			ann = &body.Items[i]
+		}
+	}
+	if ann == nil {
+		t.Fatal("Ann missing from the queue")
+	}
+	if len(ann.Metadata) != 2 || ann.Metadata["room"] != "12B" || ann.Metadata["building"] != "North" {
+		t.Errorf("metadata = %v, want room 12B and building North", ann.Metadata)
+	}
+
+	tooLong := url.Values{"name": {"Bob"}, "meta.note": {strings.Repeat("x", models.MaxMetadataValueLength+1)}}
+	if rec := postForm(web.AddToQueue, "/api/queue/add", tooLong, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("oversized metadata: status %d, want 400", rec.Code)
+	}
+}
+
+func TestGetCanStartReasons(t *testing.T) {
+	running := func(queue *models.LaundryQueue) {
+		if _, err := queue.AddAndStart("Runner", 30, 1, models.TierResident); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name   string
+		cfg    *config.Config
+		setup  func(*models.LaundryQueue)
+		who    string
+		reason string
+	}{
+		{"free machine", &config.Config{}, func(*models.LaundryQueue) {}, "Ann", ""},
+		{"not allowed", &config.Config{Allowlist: []string{"Ann"}}, func(*models.LaundryQueue) {}, "Ann, Eve", "Eve is not on the resident list"},
+		{"machines busy", &config.Config{}, running, "Ann", "Every machine is in use"},
+		{"others waiting", &config.Config{Machines: 2}, func(queue *models.LaundryQueue) {
+			running(queue)
+			queue.AddToQueue("Bob", 1, models.TierResident)
+		}, "Ann", "Others are already waiting; join the queue"},
+		{"first in line", &config.Config{Machines: 2}, func(queue *models.LaundryQueue) {
+			running(queue)
+			queue.AddToQueue("Ann", 1, models.TierResident)
+		}, "Ann", ""},
+		{"bunched start", &config.Config{Machines: 2, StartGap: 10 * time.Minute, RejectBunchedStarts: true}, running, "Ann", "Another load started moments ago; start in 10 minutes"},
+	}
+	for _, tt := range tests {
+		queue, _, api := newTestHandlers(t, tt.cfg)
+		tt.setup(queue)
+
+		rec := httptest.NewRecorder()
+		api.GetCanStart(rec, httptest.NewRequest(http.MethodGet, "/api/queue/can-start?name="+url.QueryEscape(tt.who), nil))
+		var body struct {
+			CanStart bool   `json:"can_start"`
+			Reason   string `json:"reason"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if body.CanStart != (tt.reason == "") || body.Reason != tt.reason {
+			t.Errorf("%s: can start %v (%q), want reason %q", tt.name, body.CanStart, body.Reason, tt.reason)
+		}
+	}
+}
+
+func TestGetBatchMixesFoundAndMissing(t *testing.T) {
+	queue, _, api := newTestHandlers(t, &config.Config{})
+	running, err := queue.AddAndStart("Runner", 30, 1, models.TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiting := queue.AddToQueue("Ann", 1, models.TierResident)
+
+	getBatch := func(ids string) map[string]json.RawMessage {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		api.GetBatch(rec, httptest.NewRequest(http.MethodGet, "/api/json/queue/batch?ids="+url.QueryEscape(ids), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ids %q: status %d", ids, rec.Code)
+		}
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body) != 2 || body["items"] == nil || body["missing"] == nil {
+			t.Fatalf("ids %q: body keys %v, want items and missing", ids, body)
+		}
+		return body
+	}
+
+	body := getBatch(waiting.ID + ",gone, " + running.ID + "," + waiting.ID + ",also-gone")
+	var items []QueueItemDTO
+	var missing []string
+	if err := json.Unmarshal(body["items"], &items); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(body["missing"], &missing); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ID != waiting.ID || items[1].ID != running.ID {
+		t.Errorf("items = %+v, want Ann then Runner once each", items)
+	}
+	if items[0].Position != 1 || items[1].Status != models.StatusInProgress {
+		t.Errorf("items lack their state: %+v", items)
+	}
+	if strings.Join(missing, ",") != "gone,also-gone" {
+		t.Errorf("missing = %v, want [gone also-gone]", missing)
+	}
+
+	// With nothing found, items is an empty list rather than null
+	body = getBatch("gone")
+	if string(body["items"]) != "[]" || string(body["missing"]) != `["gone"]` {
+		t.Errorf("all missing: items %s, missing %s", body["items"], body["missing"])
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"laundry-scheduler/models"
+)
+
+// icsTimeFormat is the UTC date-time format used in iCalendar files
+const icsTimeFormat = "20060102T150405Z"
+
+// icsEscaper escapes iCalendar TEXT values
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// GetItemCalendar returns an iCalendar file with a single event for a running
+// load, ending when the load is done, so it can be added to a calendar
+func (h *APIHandler) GetItemCalendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimSuffix(r.URL.Path[len("/api/queue/"):], ".ics")
+	var item *models.QueueItem
+	for _, candidate := range h.queue.GetAll() {
+		if candidate.ID == id {
+			item = candidate
+			break
+		}
+	}
+	if item == nil {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if item.Status != models.StatusInProgress || item.StartTime == nil {
+		http.Error(w, "That load isn't running", http.StatusConflict)
+		return
+	}
+
+	machine := "Washing machine"
+	if item.Drying {
+		machine = "Dryer"
+	}
+	now := time.Now()
+	// The owner's name is left out: anyone holding the load's ID can fetch
+	// this file, and it is meant to be shared into calendars
+	description := fmt.Sprintf("A %d-minute load. %d minutes remaining as of %s.",
+		item.Duration, item.GetRemainingMinutes(), now.Format("3:04 PM"))
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//laundry-scheduler//EN",
+		"BEGIN:VEVENT",
+		"UID:" + item.ID + "@laundry-scheduler",
+		"DTSTAMP:" + now.UTC().Format(icsTimeFormat),
+		"DTSTART:" + item.StartTime.UTC().Format(icsTimeFormat),
+		"DTEND:" + item.EndTime().UTC().Format(icsTimeFormat),
+		"SUMMARY:Laundry done",
+		"LOCATION:" + icsEscaper.Replace(machine),
+		"DESCRIPTION:" + icsEscaper.Replace(description),
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:Laundry done",
+		"TRIGGER;RELATED=END:PT0M",
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "laundry-"+item.ID+".ics"))
+	for _, line := range lines {
+		fmt.Fprint(w, foldICSLine(line)+"\r\n")
+	}
+}
+
+// foldICSLine splits a content line longer than 75 octets into continuation
+// lines, without breaking a multi-byte character
+func foldICSLine(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
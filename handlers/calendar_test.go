@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"laundry-scheduler/config"
+	"laundry-scheduler/models"
+)
+
+func TestGetItemCalendarSingleEvent(t *testing.T) {
+	queue, _, api := newTestHandlers(t, &config.Config{})
+	item, err := queue.AddAndStart("Runner", 45, 1, models.TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiting := queue.AddToQueue("Waiting", 1, models.TierResident)
+
+	rec := httptest.NewRecorder()
+	api.GetItemCalendar(rec, httptest.NewRequest(http.MethodGet, "/api/queue/"+item.ID+".ics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Errorf("content type %q", ct)
+	}
+
+	body := rec.Body.String()
+	lines := strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n")
+	if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+		t.Errorf("not wrapped in a calendar:\n%s", body)
+	}
+	if n := strings.Count(body, "BEGIN:VEVENT"); n != 1 {
+		t.Errorf("%d events, want 1", n)
+	}
+	if strings.Contains(body, "Runner") {
+		t.Errorf("calendar names the load's owner:\n%s", body)
+	}
+
+	wantEnd := item.StartTime.Add(45 * time.Minute)
+	var end time.Time
+	for _, line := range lines {
+		if value, ok := strings.CutPrefix(line, "DTEND:"); ok {
+			end, err = time.Parse(icsTimeFormat, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if diff := end.Sub(wantEnd); diff < -time.Second || diff > time.Second {
+		t.Errorf("event ends %s, want %s", end, wantEnd.UTC())
+	}
+
+	for path, want := range map[string]int{
+		"/api/queue/" + waiting.ID + ".ics": http.StatusConflict,
+		"/api/queue/missing.ics":            http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		api.GetItemCalendar(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
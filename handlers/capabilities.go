@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"laundry-scheduler/models"
+)
+
+// Capabilities describes which optional features this deployment has enabled
+// and the limits it enforces, so one frontend can adapt to any configuration
+type Capabilities struct {
+	Features        map[string]bool `json:"features"`
+	MachineCount    int             `json:"machine_count"`
+	MaxNumLoads     int             `json:"max_num_loads"`
+	MinTimerMinutes int             `json:"min_timer_minutes"`
+	MaxTimerMinutes int             `json:"max_timer_minutes"`
+	MaxStartDelay   int             `json:"max_start_delay_minutes"`
+	DurationPresets map[string]int  `json:"duration_presets"`
+	Languages       []string        `json:"languages"`
+}
+
+// GetCapabilities returns the enabled features and limits of this deployment
+func (h *APIHandler) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	maxTimer := h.queue.MaxLoadMinutes()
+	if maxTimer == 0 {
+		maxTimer = models.MaxDurationMinutes
+	}
+
+	languages := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+
+	writeJSON(w, http.StatusOK, Capabilities{
+		Features: map[string]bool{
+			"transit":            h.config.TransitTimeout > 0,
+			"auto_remove":        h.queue.AutoRemoveEnabled(),
+			"auto_start":         h.config.AutoStartMinutes > 0,
+			"delayed_start":      true,
+			"idle_alerts":        h.config.IdleAlertAfter > 0,
+			"absent_detection":   h.config.AbsentAfter > 0,
+			"auto_skip_absent":   h.config.AbsentAfter > 0 && h.config.AutoSkipAbsent,
+			"requeue_priority":   h.config.RequeuePriority,
+			"state_replay":       h.config.TimelineRetention > 0,
+			"history":            h.config.HistorySize > 0,
+			"persistence":        h.config.StateFile != "",
+			"collapse_completed": h.config.CollapseCompleted,
+			"start_stagger":      h.config.StartGap > 0,
+			"allowlist":          len(h.config.Allowlist) > 0,
+			"admin":              h.config.AdminToken != "",
+			// GET /api/queue answers If-Modified-Since with 304 only while
+			// nothing is waiting or running, as their countdowns change by the minute
+			"conditional_get": true,
+		},
+		MachineCount:    h.queue.Machines(),
+		MaxNumLoads:     MaxNumLoads,
+		MinTimerMinutes: 1,
+		MaxTimerMinutes: maxTimer,
+		MaxStartDelay:   MaxStartDelayMinutes,
+		DurationPresets: h.config.DurationPresets,
+		Languages:       languages,
+	})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"laundry-scheduler/config"
+	"laundry-scheduler/models"
+)
+
+// getCapabilities fetches the capabilities of a deployment configured by cfg
+func getCapabilities(t *testing.T, cfg *config.Config) Capabilities {
+	t.Helper()
+	_, _, api := newTestHandlers(t, cfg)
+
+	rec := httptest.NewRecorder()
+	api.GetCapabilities(rec, httptest.NewRequest(http.MethodGet, "/api/capabilities", nil))
+	var caps Capabilities
+	if err := json.NewDecoder(rec.Body).Decode(&caps); err != nil {
+		t.Fatal(err)
+	}
+	return caps
+}
+
+func TestCapabilitiesAdvertiseEnabledFeatures(t *testing.T) {
+	caps := getCapabilities(t, &config.Config{
+		HistorySize:       10,
+		StateFile:         filepath.Join(t.TempDir(), "queue.json"),
+		CollapseCompleted: true,
+	})
+	for _, feature := range []string{"history", "persistence", "collapse_completed"} {
+		if !caps.Features[feature] {
+			t.Errorf("feature %q not advertised", feature)
+		}
+	}
+	if caps.Features["state_replay"] {
+		t.Error("state_replay advertised without a timeline")
+	}
+}
+
+func TestCapabilitiesReflectConfig(t *testing.T) {
+	defaults := getCapabilities(t, &config.Config{})
+	if defaults.MachineCount != 1 || defaults.MaxTimerMinutes != models.MaxDurationMinutes {
+		t.Errorf("default machines %d, max timer %d", defaults.MachineCount, defaults.MaxTimerMinutes)
+	}
+	for _, feature := range []string{"transit", "admin", "start_stagger", "allowlist"} {
+		if defaults.Features[feature] {
+			t.Errorf("feature %q advertised by default", feature)
+		}
+	}
+	if !defaults.Features["conditional_get"] {
+		t.Error("conditional_get not advertised by default")
+	}
+
+	caps := getCapabilities(t, &config.Config{
+		Machines:        3,
+		MaxLoadDuration: 90 * time.Minute,
+		TransitTimeout:  10 * time.Minute,
+		StartGap:        5 * time.Minute,
+		AdminToken:      "secret",
+		Allowlist:       []string{"Ann"},
+		DurationPresets: map[string]int{"quick": 30},
+	})
+	if caps.MachineCount != 3 {
+		t.Errorf("machine count %d, want 3", caps.MachineCount)
+	}
+	if caps.MinTimerMinutes != 1 || caps.MaxTimerMinutes != 90 {
+		t.Errorf("timer limits %d-%d, want 1-90", caps.MinTimerMinutes, caps.MaxTimerMinutes)
+	}
+	if caps.MaxNumLoads != models.MaxNumLoads {
+		t.Errorf("max loads %d, want %d", caps.MaxNumLoads, models.MaxNumLoads)
+	}
+	for _, feature := range []string{"transit", "admin", "start_stagger", "allowlist"} {
+		if !caps.Features[feature] {
+			t.Errorf("feature %q not advertised", feature)
+		}
+	}
+	if caps.DurationPresets["quick"] != 30 {
+		t.Errorf("presets %v, want quick=30", caps.DurationPresets)
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"math"
+	"time"
+
+	"laundry-scheduler/models"
+)
+
+// QueueItemDTO is the public JSON representation of a queue item. It carries
+// only fields that are safe to show everyone, plus values computed at
+// response time, so the storage model can change without breaking clients.
+type QueueItemDTO struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Owners           []string          `json:"owners,omitempty"`
+	Status           string            `json:"status"`
+	Tier             string            `json:"tier"`
+	NumLoads         int               `json:"num_loads"`
+	QueuedAt         time.Time         `json:"queued_at"`
+	StartTime        *time.Time        `json:"start_time,omitempty"`
+	Duration         int               `json:"duration,omitempty"`
+	Drying           bool              `json:"drying,omitempty"`
+	TransitAt        *time.Time        `json:"transit_at,omitempty"`
+	CompletedAt      *time.Time        `json:"completed_at,omitempty"`
+	PausedAt         *time.Time        `json:"paused_at,omitempty"`
+	Position         int               `json:"position,omitempty"`
+	RemainingMinutes int               `json:"remaining_minutes"`
+	ETAMinutes       int               `json:"eta_minutes"`
+	EstimatedStart   *time.Time        `json:"estimated_start,omitempty"`
+	Urgency          string            `json:"urgency,omitempty"`
+	PossiblyAbsent   bool              `json:"possibly_absent,omitempty"`
+	AssistedBy       string            `json:"assisted_by,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	MachineID        int               `json:"machine_id,omitempty"`
+	AutoRemoveIn     int               `json:"auto_remove_in_minutes,omitempty"`
+}
+
+// newQueueItemDTOs maps items to their public representation. Positions and
+// ETAs are computed against all, a snapshot of the whole queue.
+func newQueueItemDTOs(queue *models.LaundryQueue, all, items []*models.QueueItem) []QueueItemDTO {
+	now := time.Now()
+	positions := models.WaitingPositions(all, queue.TierWeights())
+	starts := models.EstimateStarts(all, queue.TierWeights(), queue.Machines(), queue.LoadMinutes(), now)
+	soon, finishing := queue.UrgencyMinutes()
+	dtos := make([]QueueItemDTO, 0, len(items))
+	for _, item := range items {
+		var eta int
+		var estimatedStart *time.Time
+		if start, ok := starts[item.ID]; ok {
+			estimatedStart = &start
+			eta = minutesUntil(start)
+		} else {
+			eta = models.ETAMinutes(all, item, queue.TierWeights(), queue.Machines(), queue.LoadMinutes(), now)
+		}
+		dto := snapshotDTO(item)
+		dto.Position = positions[item.ID]
+		dto.RemainingMinutes = item.GetRemainingMinutes()
+		dto.ETAMinutes = eta
+		dto.EstimatedStart = estimatedStart
+		dto.Urgency = item.Urgency(soon, finishing)
+		dto.AutoRemoveIn = item.AutoRemoveInMinutes()
+		dtos = append(dtos, dto)
+	}
+	return dtos
+}
+
+// snapshotDTO maps only the stored fields of item, leaving out values computed
+// against the current queue, for items that describe a past moment such as
+// history entries
+func snapshotDTO(item *models.QueueItem) QueueItemDTO {
+	return QueueItemDTO{
+		ID:             item.ID,
+		Name:           item.Name,
+		Owners:         item.Owners,
+		Status:         item.Status,
+		Tier:           item.Tier,
+		NumLoads:       item.NumLoads,
+		QueuedAt:       item.QueuedAt,
+		StartTime:      item.StartTime,
+		Duration:       item.Duration,
+		Drying:         item.Drying,
+		TransitAt:      item.TransitAt,
+		CompletedAt:    item.CompletedAt,
+		PausedAt:       item.PausedAt,
+		PossiblyAbsent: item.PossiblyAbsent,
+		AssistedBy:     item.AssistedBy,
+		Metadata:       item.Metadata,
+		MachineID:      item.MachineID,
+	}
+}
+
+// newSnapshotDTOs maps items describing a past moment to their public
+// representation with snapshotDTO
+func newSnapshotDTOs(items []*models.QueueItem) []QueueItemDTO {
+	dtos := make([]QueueItemDTO, 0, len(items))
+	for _, item := range items {
+		dtos = append(dtos, snapshotDTO(item))
+	}
+	return dtos
+}
+
+// ForecastEntryDTO is the public JSON representation of one projected load
+type ForecastEntryDTO struct {
+	ID    string    `json:"id"`
+	Name  string    `json:"name"`
+	Start time.Time `json:"projected_start"`
+	End   time.Time `json:"projected_end"`
+}
+
+// ForecastDTO is the public JSON representation of a queue forecast
+type ForecastDTO struct {
+	At       time.Time          `json:"at"`
+	Running  []ForecastEntryDTO `json:"running"`
+	Waiting  []ForecastEntryDTO `json:"waiting"`
+	Finished []ForecastEntryDTO `json:"finished"`
+	FreeAt   time.Time          `json:"free_at"`
+}
+
+// newForecastDTO maps a forecast to its public representation
+func newForecastDTO(forecast models.Forecast) ForecastDTO {
+	entries := func(list []models.ForecastEntry) []ForecastEntryDTO {
+		dtos := make([]ForecastEntryDTO, 0, len(list))
+		for _, entry := range list {
+			dtos = append(dtos, ForecastEntryDTO{ID: entry.ID, Name: entry.Name, Start: entry.Start, End: entry.End})
+		}
+		return dtos
+	}
+	return ForecastDTO{
+		At:       forecast.At,
+		Running:  entries(forecast.Running),
+		Waiting:  entries(forecast.Waiting),
+		Finished: entries(forecast.Finished),
+		FreeAt:   forecast.FreeAt,
+	}
+}
+
+// minutesUntil returns the whole minutes, rounded up, from now until t, or 0
+// if t has passed
+func minutesUntil(t time.Time) int {
+	if left := time.Until(t); left > 0 {
+		return int(math.Ceil(left.Minutes()))
+	}
+	return 0
+}
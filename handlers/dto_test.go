@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"laundry-scheduler/config"
+	"laundry-scheduler/models"
+)
+
+func TestQueueItemDTOCarriesOnlyPublicFields(t *testing.T) {
+	queue, _, _ := newTestHandlers(t, &config.Config{})
+	item, err := queue.AddAndStartWithDetails("Ann, Bob", 30, 2, models.TierStaff,
+		models.ItemDetails{Metadata: map[string]string{"room": "12"}, AssistedBy: "Pat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(newQueueItemDTOs(queue, queue.GetAll(), []*models.QueueItem{item})[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		if strings.Contains(key, "token") || strings.Contains(key, "contact") {
+			t.Errorf("DTO exposes %q", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	want := "assisted_by,duration,eta_minutes,id,machine_id,metadata,name,num_loads,owners," +
+		"queued_at,remaining_minutes,start_time,status,tier,urgency"
+	if got := strings.Join(keys, ","); got != want {
+		t.Errorf("DTO fields %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is used when the client accepts none of the catalog languages
+const DefaultLanguage = "en"
+
+// catalogs holds the UI strings for each supported language
+var catalogs = map[string]map[string]string{
+	"en": {
+		"status.waiting":     "Waiting",
+		"status.in_progress": "In Progress",
+		"status.starting":    "Starting Soon",
+		"status.expired":     "Timer Expired!",
+		"status.drying":      "Drying",
+		"status.in_transit":  "Moving to Dryer",
+		"status.completed":   "Done (removing soon)",
+		"status.done":        "Done",
+		"one_load_planned":   "1 load planned",
+		"loads_planned":      "loads planned",
+		"position":           "Position in queue",
+		"est_wait":           "Est. wait",
+		"now":                "now",
+		"started":            "Started",
+		"starts_in":          "Starts in",
+		"delay":              "Delay (min)",
+		"duration":           "Duration",
+		"remaining":          " remaining",
+		"complete":           "Complete",
+		"completed_at":       "Completed at",
+		"auto_removing":      "Auto-removing in a few minutes...",
+		"wash_finished":      "Wash finished at",
+		"washer_free":        "The washer is free.",
+		"minutes":            "Minutes",
+		"start_timer":        "Start Timer",
+		"start_dryer":        "Start Dryer",
+		"leave_queue":        "Leave Queue",
+		"cancel_start":       "Cancel Start",
+		"clear":              "Clear",
+		"empty":              "No one in the queue. The washing machine is available!",
+		"possibly_absent":    "May have stepped away",
+		"requeue":            "Queue Another Load",
+		"add_to_calendar":    "Add to calendar",
+		"recently_done":      "finished recently (show)",
+		"service":            "Service",
+		"paused.title":       "Room paused",
+		"paused.body":        "All timers are frozen and will continue from where they stopped.",
+		"service_by":         "Laundry service by",
+		"not_found.title":    "Page not found",
+		"not_found.body":     "There's nothing at",
+		"not_found.home":     "Back to the queue",
+		"stagger_hint":       "A load just started. To stagger finishes, delay your start by",
+		"machine":            "Machine",
+		"around":             "around",
+		"finished_early":     "Finished Early",
+	},
+	"es": {
+		"status.waiting":     "En espera",
+		"status.in_progress": "En curso",
+		"status.starting":    "Empieza pronto",
+		"status.expired":     "¡Tiempo agotado!",
+		"status.drying":      "Secando",
+		"status.in_transit":  "Pasando a la secadora",
+		"status.completed":   "Terminado (se quitará pronto)",
+		"status.done":        "Terminado",
+		"one_load_planned":   "1 carga prevista",
+		"loads_planned":      "cargas previstas",
+		"position":           "Posición en la cola",
+		"est_wait":           "Espera estimada",
+		"now":                "ahora",
+		"started":            "Inicio",
+		"starts_in":          "Empieza en",
+		"delay":              "Retraso (min)",
+		"duration":           "Duración",
+		"remaining":          " restantes",
+		"complete":           "Terminado",
+		"completed_at":       "Terminado a las",
+		"auto_removing":      "Se quitará en unos minutos...",
+		"wash_finished":      "Lavado terminado a las",
+		"washer_free":        "La lavadora está libre.",
+		"minutes":            "Minutos",
+		"start_timer":        "Iniciar temporizador",
+		"start_dryer":        "Iniciar secadora",
+		"leave_queue":        "Salir de la cola",
+		"cancel_start":       "Cancelar inicio",
+		"clear":              "Quitar",
+		"empty":              "No hay nadie en la cola. ¡La lavadora está disponible!",
+		"possibly_absent":    "Puede que se haya ido",
+		"requeue":            "Poner otra carga en cola",
+		"add_to_calendar":    "Añadir al calendario",
+		"recently_done":      "terminadas recientemente (mostrar)",
+		"service":            "Servicio",
+		"paused.title":       "Sala en pausa",
+		"paused.body":        "Todos los temporizadores están detenidos y seguirán desde donde se quedaron.",
+		"service_by":         "Servicio de lavandería por",
+		"not_found.title":    "Página no encontrada",
+		"not_found.body":     "No hay nada en",
+		"not_found.home":     "Volver a la cola",
+		"stagger_hint":       "Una carga acaba de empezar. Para escalonar los finales, retrasa tu inicio",
+		"machine":            "Lavadora",
+		"around":             "hacia las",
+		"finished_early":     "Terminó antes",
+	},
+}
+
+// translate looks up a UI string, falling back to English and then the key itself
+func translate(lang, key string) string {
+	if msg, ok := catalogs[lang][key]; ok {
+		return msg
+	}
+	if msg, ok := catalogs[DefaultLanguage][key]; ok {
+		return msg
+	}
+	return key
+}
+
+// negotiateLanguage picks the catalog language that best matches the
+// request's Accept-Language header
+func negotiateLanguage(r *http.Request) string {
+	type candidate struct {
+		lang    string
+		quality float64
+	}
+
+	candidates := make([]candidate, 0)
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := catalogs[lang]; !ok {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if quality > 0 {
+			candidates = append(candidates, candidate{lang, quality})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return DefaultLanguage
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	return candidates[0].lang
+}
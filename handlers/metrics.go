@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MaxSlowRequests is how many of the most recent slow requests are kept
+const MaxSlowRequests = 50
+
+// RouteStats summarizes the latency of one route
+type RouteStats struct {
+	Count int64   `json:"count"`
+	AvgMs float64 `json:"avg_ms"`
+	MaxMs float64 `json:"max_ms"`
+	Slow  int64   `json:"slow"`
+
+	total time.Duration
+	max   time.Duration
+}
+
+// SlowRequest is a request that took longer than the slow threshold
+type SlowRequest struct {
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Route      string    `json:"route"`
+	DurationMs float64   `json:"duration_ms"`
+	At         time.Time `json:"at"`
+}
+
+// RequestMetrics tracks latency per route and remembers recent requests
+// slower than a threshold, to catch slow templates or lock contention
+type RequestMetrics struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	routes    map[string]*RouteStats
+	slow      []SlowRequest
+}
+
+// NewRequestMetrics creates metrics that log and keep requests taking longer
+// than threshold. A zero threshold tracks latency without flagging anything.
+func NewRequestMetrics(threshold time.Duration) *RequestMetrics {
+	return &RequestMetrics{
+		threshold: threshold,
+		routes:    make(map[string]*RouteStats),
+		slow:      make([]SlowRequest, 0),
+	}
+}
+
+// Track wraps mux so every request's latency is recorded under the mux
+// pattern that served it, such as "/api/queue/" for any item route
+func (m *RequestMetrics) Track(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		mux.ServeHTTP(w, r)
+
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = r.URL.Path
+		}
+		m.record(r.Method, r.URL.Path, route, start, time.Since(start))
+	})
+}
+
+// record adds one request's latency, logging it if it was slow
+func (m *RequestMetrics) record(method, path, route string, start time.Time, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.routes[route]
+	if !ok {
+		stats = &RouteStats{}
+		m.routes[route] = stats
+	}
+	stats.Count++
+	stats.total += elapsed
+	if elapsed > stats.max {
+		stats.max = elapsed
+	}
+
+	if m.threshold <= 0 || elapsed < m.threshold {
+		return
+	}
+	stats.Slow++
+	log.Printf("Slow request: %s %s (route %s) took %s", method, path, route, elapsed)
+	m.slow = append(m.slow, SlowRequest{
+		Method:     method,
+		Path:       path,
+		Route:      route,
+		DurationMs: milliseconds(elapsed),
+		At:         start,
+	})
+	if len(m.slow) > MaxSlowRequests {
+		m.slow = m.slow[len(m.slow)-MaxSlowRequests:]
+	}
+}
+
+// Slow returns the recent slow requests, newest first
+func (m *RequestMetrics) Slow() []SlowRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]SlowRequest, len(m.slow))
+	for i, req := range m.slow {
+		result[len(m.slow)-1-i] = req
+	}
+	return result
+}
+
+// Routes returns the latency summary of every route seen so far
+func (m *RequestMetrics) Routes() map[string]RouteStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]RouteStats, len(m.routes))
+	for route, stats := range m.routes {
+		summary := *stats
+		summary.AvgMs = milliseconds(stats.total) / float64(stats.Count)
+		summary.MaxMs = milliseconds(stats.max)
+		result[route] = summary
+	}
+	return result
+}
+
+// GetSlow returns the recent slow requests and per-route latency
+func (m *RequestMetrics) GetSlow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		ThresholdMs float64               `json:"threshold_ms"`
+		Slow        []SlowRequest         `json:"slow"`
+		Routes      map[string]RouteStats `json:"routes"`
+	}{milliseconds(m.threshold), m.Slow(), m.Routes()})
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
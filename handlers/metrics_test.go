@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowRequestsAreLoggedAndListed(t *testing.T) {
+	var logged bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logged)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow/", func(w http.ResponseWriter, r *http.Request) { time.Sleep(50 * time.Millisecond) })
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {})
+	metrics := NewRequestMetrics(20 * time.Millisecond)
+	tracked := metrics.Track(mux)
+	for _, path := range []string{"/fast", "/slow/1", "/fast"} {
+		tracked.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if !strings.Contains(logged.String(), "Slow request: GET /slow/1 (route /slow/)") {
+		t.Errorf("slow request not logged: %q", logged.String())
+	}
+	if strings.Contains(logged.String(), "/fast") {
+		t.Errorf("fast request logged as slow: %q", logged.String())
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.GetSlow(rec, httptest.NewRequest(http.MethodGet, "/api/debug/slow", nil))
+	var body struct {
+		ThresholdMs float64               `json:"threshold_ms"`
+		Slow        []SlowRequest         `json:"slow"`
+		Routes      map[string]RouteStats `json:"routes"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.ThresholdMs != 20 {
+		t.Errorf("threshold %vms, want 20ms", body.ThresholdMs)
+	}
+	if len(body.Slow) != 1 || body.Slow[0].Path != "/slow/1" || body.Slow[0].Route != "/slow/" || body.Slow[0].DurationMs < 50 {
+		t.Errorf("slow requests = %+v, want only /slow/1", body.Slow)
+	}
+	if fast := body.Routes["/fast"]; fast.Count != 2 || fast.Slow != 0 {
+		t.Errorf("/fast stats = %+v, want 2 requests, none slow", fast)
+	}
+	if slow := body.Routes["/slow/"]; slow.Count != 1 || slow.Slow != 1 || slow.MaxMs < 50 {
+		t.Errorf("/slow/ stats = %+v, want 1 slow request of at least 50ms", slow)
+	}
+}
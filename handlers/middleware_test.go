@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecoverAnswersPanicsWith500(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	server := httptest.NewServer(Recover(mux))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || resp.Header.Get(RequestIDHeader) == "" {
+		t.Errorf("panicking route: status %d, request ID %q; want 500 with an ID", resp.StatusCode, resp.Header.Get(RequestIDHeader))
+	}
+
+	resp, err = http.Get(server.URL + "/ok")
+	if err != nil {
+		t.Fatalf("server stopped answering after a panic: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("route after a panic: status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+}
+
+func TestRedirectToHTTPSKeepsHostAndPath(t *testing.T) {
+	tests := []struct {
+		httpsAddr, host, want string
+	}{
+		{":8443", "laundry.example:8080", "https://laundry.example:8443/api/queue?me=1"},
+		{":443", "laundry.example:8080", "https://laundry.example/api/queue?me=1"},
+		{":8443", "laundry.example", "https://laundry.example:8443/api/queue?me=1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/queue?me=1", nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		RedirectToHTTPS(tt.httpsAddr).ServeHTTP(rec, req)
+		if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != tt.want {
+			t.Errorf("%s via %s: %d to %q, want 301 to %q", tt.host, tt.httpsAddr, rec.Code, rec.Header().Get("Location"), tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"laundry-scheduler/models"
+)
+
+// MaxRosterBytes limits the size of an uploaded roster
+const MaxRosterBytes = 1 << 20
+
+// rosterRow is a single participant in an imported roster. NumLoads is nil
+// when the row leaves it out, which means one load.
+type rosterRow struct {
+	Name     string `json:"name"`
+	NumLoads *int   `json:"num_loads"`
+}
+
+// RosterResult reports what happened to one roster row
+type RosterResult struct {
+	Row   int    `json:"row"`
+	Name  string `json:"name"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ImportRoster enqueues a list of participants as waiting items, in order.
+// The body is a JSON array of {"name", "num_loads"} objects, or CSV with
+// name and optional loads columns when sent as text/csv. Each row is
+// validated separately and the per-row outcome is returned.
+func (h *APIHandler) ImportRoster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, MaxRosterBytes)
+	var rows []rosterRow
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		rows, err = parseRosterCSV(body)
+	} else {
+		err = json.NewDecoder(body).Decode(&rows)
+	}
+	if err != nil {
+		http.Error(w, "Invalid roster: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]RosterResult, 0, len(rows))
+	for i, row := range rows {
+		result := RosterResult{Row: i + 1, Name: strings.TrimSpace(row.Name)}
+		numLoads := 1
+		if row.NumLoads != nil {
+			numLoads = *row.NumLoads
+		}
+
+		owners := models.ParseOwners(result.Name)
+		switch {
+		case len(owners) == 0:
+			result.Error = "name is required"
+		case numLoads < 1 || numLoads > models.MaxNumLoads:
+			result.Error = fmt.Sprintf("invalid number of loads (must be 1-%d)", models.MaxNumLoads)
+		default:
+			result.Error = h.disallowedOwner(owners)
+		}
+		if result.Error == "" {
+			result.ID = h.queue.AddToQueue(result.Name, numLoads, models.TierResident).ID
+		}
+		results = append(results, result)
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// disallowedOwner returns an error message naming the first of owners who is
+// not on the resident list, or "" if they all are
+func (h *APIHandler) disallowedOwner(owners []string) string {
+	for _, owner := range owners {
+		if !h.config.IsAllowed(owner) {
+			return fmt.Sprintf("%s is not on the resident list", owner)
+		}
+	}
+	return ""
+}
+
+// parseRosterCSV reads name[,loads] records, skipping a leading header row
+func parseRosterCSV(r io.Reader) ([]rosterRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows := make([]rosterRow, 0)
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "name") {
+			continue
+		}
+
+		row := rosterRow{Name: record[0]}
+		if len(record) > 1 && strings.TrimSpace(record[1]) != "" {
+			loads, err := strconv.Atoi(strings.TrimSpace(record[1]))
+			if err != nil {
+				// Keep the row so it is reported alongside the others
+				loads = -1
+			}
+			row.NumLoads = &loads
+		}
+		rows = append(rows, row)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"laundry-scheduler/config"
+)
+
+func TestImportRosterChecksEveryOwner(t *testing.T) {
+	_, _, api := newTestHandlers(t, &config.Config{Allowlist: []string{"Ann", "Bob"}})
+
+	body := `[{"name": "Ann, Bob"}, {"name": "Ann, Mallory"}, {"name": "Bob", "num_loads": 11}, {"name": "Ann", "num_loads": 0}]`
+	req := httptest.NewRequest(http.MethodPost, "/api/roster", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	api.ImportRoster(rec, req)
+
+	var results []RosterResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	if results[0].ID == "" || results[0].Error != "" {
+		t.Errorf("shared load of allowed owners: %+v", results[0])
+	}
+	if results[1].ID != "" || !strings.Contains(results[1].Error, "Mallory") {
+		t.Errorf("shared load with an unlisted owner: %+v", results[1])
+	}
+	if results[2].ID != "" || results[2].Error == "" {
+		t.Errorf("too many loads: %+v", results[2])
+	}
+	if results[3].ID != "" || results[3].Error == "" {
+		t.Errorf("explicit zero loads: %+v", results[3])
+	}
+}
+
+func TestImportRosterCSVKeepsOrder(t *testing.T) {
+	queue, _, api := newTestHandlers(t, &config.Config{})
+
+	body := "name,loads\nCat,2\nAnn\nBob,x\nDan,1\nBob,0\n"
+	req := httptest.NewRequest(http.MethodPost, "/api/queue/import-roster", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := httptest.NewRecorder()
+	api.ImportRoster(rec, req)
+
+	var results []RosterResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if result.Row != i+1 {
+			t.Errorf("result %d reports row %d", i, result.Row)
+		}
+		if failed := result.Error != ""; failed != (result.Name == "Bob") {
+			t.Errorf("row %d (%s): error %q", result.Row, result.Name, result.Error)
+		}
+	}
+
+	names := make([]string, 0)
+	for _, item := range queue.GetAll() {
+		names = append(names, item.Name)
+	}
+	if got := strings.Join(names, ","); got != "Cat,Ann,Dan" {
+		t.Errorf("queued %s, want Cat,Ann,Dan in roster order", got)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"laundry-scheduler/models"
+)
+
+// MaxTableNameWidth is the widest a name may be in the ASCII queue table
+// before it is truncated with an ellipsis
+const MaxTableNameWidth = 20
+
+// GetQueueTable returns the queue as a fixed-width plain text table, for
+// terminals and chat
+func (h *APIHandler) GetQueueTable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, queueTable(h.queue.GetAll(), h.queue.TierWeights()))
+}
+
+// queueTable lays out items as an ASCII table with a position, name, status
+// and remaining time column. Loads on the machine come first, then the
+// waiting line in the order weights gives it.
+func queueTable(items []*models.QueueItem, weights map[string]int) string {
+	header := []string{"#", "Name", "Status", "Remaining"}
+	rows := make([][]string, 0, len(items))
+	byID := make(map[string]*models.QueueItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+		if item.Status == models.StatusWaiting {
+			continue
+		}
+		status := item.Status
+		if item.IsPending() {
+			status = "starting"
+		}
+		remaining := "-"
+		if item.Status == models.StatusInProgress {
+			remaining = fmt.Sprintf("%d min", item.GetRemainingMinutes())
+		}
+		rows = append(rows, []string{"-", truncateName(item.Name), status, remaining})
+	}
+	for _, pos := range models.SortedPositions(items, weights) {
+		item := byID[pos.ID]
+		rows = append(rows, []string{strconv.Itoa(pos.Position), truncateName(item.Name), item.Status, "-"})
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var b strings.Builder
+	border := "+"
+	for _, width := range widths {
+		border += strings.Repeat("-", width+2) + "+"
+	}
+	writeRow := func(row []string) {
+		b.WriteString("|")
+		for i, cell := range row {
+			b.WriteString(" " + cell + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)) + " |")
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(border + "\n")
+	writeRow(header)
+	b.WriteString(border + "\n")
+	for _, row := range rows {
+		writeRow(row)
+	}
+	if len(rows) > 0 {
+		b.WriteString(border + "\n")
+	}
+	return b.String()
+}
+
+// truncateName shortens a name to MaxTableNameWidth characters, ending it
+// with an ellipsis when cut
+func truncateName(name string) string {
+	if utf8.RuneCountInString(name) <= MaxTableNameWidth {
+		return name
+	}
+	return string([]rune(name)[:MaxTableNameWidth-3]) + "..."
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"laundry-scheduler/models"
+)
+
+func TestQueueTableLayout(t *testing.T) {
+	now := time.Now()
+	start := now.Add(-15*time.Minute + 30*time.Second)
+	items := []*models.QueueItem{
+		{ID: "1", Name: "Runner", Status: models.StatusInProgress, StartTime: &start, Duration: 45, QueuedAt: now.Add(-20 * time.Minute)},
+		{ID: "2", Name: "Ann", Status: models.StatusWaiting, Tier: models.TierResident, QueuedAt: now.Add(-10 * time.Minute)},
+		{ID: "3", Name: "Bartholomew Fitzgerald Longname", Status: models.StatusWaiting, Tier: models.TierResident, QueuedAt: now.Add(-5 * time.Minute)},
+	}
+
+	want := "" +
+		"+---+----------------------+-------------+-----------+\n" +
+		"| # | Name                 | Status      | Remaining |\n" +
+		"+---+----------------------+-------------+-----------+\n" +
+		"| - | Runner               | in_progress | 30 min    |\n" +
+		"| 1 | Ann                  | waiting     | -         |\n" +
+		"| 2 | Bartholomew Fitzg... | waiting     | -         |\n" +
+		"+---+----------------------+-------------+-----------+\n"
+	if got := queueTable(items, models.DefaultTierWeights()); got != want {
+		t.Errorf("table =\n%s\nwant\n%s", got, want)
+	}
+
+	empty := "" +
+		"+---+------+--------+-----------+\n" +
+		"| # | Name | Status | Remaining |\n" +
+		"+---+------+--------+-----------+\n"
+	if got := queueTable(nil, models.DefaultTierWeights()); got != empty {
+		t.Errorf("empty table =\n%s\nwant\n%s", got, empty)
+	}
+}
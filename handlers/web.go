@@ -1,15 +1,20 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"laundry-scheduler/config"
 	"laundry-scheduler/models"
 )
 
@@ -20,17 +25,56 @@ const (
 	TemplatesDir = "templates"
 	// StaticDir is the directory containing static files
 	StaticDir = "./static"
+	// MaxStartDelayMinutes is the longest a start may be delayed
+	MaxStartDelayMinutes = 30
+	// MaxNumLoads is the most loads one person may queue at once
+	MaxNumLoads = models.MaxNumLoads
+	// MetadataFieldPrefix marks add-form fields that become item metadata
+	MetadataFieldPrefix = "meta."
 )
 
 // WebHandler handles HTTP requests for the laundry queue application
 type WebHandler struct {
 	queue     *models.LaundryQueue
-	templates *template.Template
+	config    *config.Config
+	templates map[string]*template.Template
 }
 
-// NewWebHandler creates a new web handler with initialized templates
-func NewWebHandler(queue *models.LaundryQueue) *WebHandler {
-	funcMap := template.FuncMap{
+// NewWebHandler creates a new web handler with templates initialized for each catalog language
+func NewWebHandler(queue *models.LaundryQueue, cfg *config.Config) *WebHandler {
+	templatePath := filepath.Join(TemplatesDir, "*.html")
+
+	if _, err := os.Stat(TemplatesDir); os.IsNotExist(err) {
+		log.Fatal("templates directory not found! Make sure you're running from the project root directory")
+	}
+
+	templates := make(map[string]*template.Template)
+	for lang := range catalogs {
+		tmpl, err := template.New("").Funcs(templateFuncs(lang, queue)).ParseGlob(templatePath)
+		if err != nil {
+			log.Fatalf("Error parsing templates: %v", err)
+		}
+		templates[lang] = tmpl
+	}
+
+	return &WebHandler{
+		queue:     queue,
+		config:    cfg,
+		templates: templates,
+	}
+}
+
+// templateFuncs returns the template helpers, with UI strings drawn from
+// lang's catalog and estimates from queue
+func templateFuncs(lang string, queue *models.LaundryQueue) template.FuncMap {
+	return template.FuncMap{
+		"estimatedStart": queue.EstimatedStartTime,
+		"t": func(key string) string {
+			return translate(lang, key)
+		},
+		// pathEscape makes a value safe to use as one segment of a request
+		// path, which contextual escaping alone doesn't guarantee
+		"pathEscape": url.PathEscape,
 		"formatTime": func(t *time.Time) string {
 			if t == nil {
 				return ""
@@ -39,7 +83,7 @@ func NewWebHandler(queue *models.LaundryQueue) *WebHandler {
 		},
 		"formatTimeRange": func(minutes int, suffix string) string {
 			if minutes <= 0 {
-				return "Complete"
+				return translate(lang, "complete")
 			}
 			if minutes < 60 {
 				return fmt.Sprintf("%d min%s", minutes, suffix)
@@ -52,72 +96,178 @@ func NewWebHandler(queue *models.LaundryQueue) *WebHandler {
 			return fmt.Sprintf("%dh%s", hours, suffix)
 		},
 	}
-
-	templatePath := filepath.Join(TemplatesDir, "*.html")
-
-	if _, err := os.Stat(TemplatesDir); os.IsNotExist(err) {
-		log.Fatal("templates directory not found! Make sure you're running from the project root directory")
-	}
-
-	tmpl, err := template.New("").Funcs(funcMap).ParseGlob(templatePath)
-	if err != nil {
-		log.Fatalf("Error parsing templates: %v", err)
-	}
-
-	return &WebHandler{
-		queue:     queue,
-		templates: tmpl,
-	}
 }
 
-// executeTemplate executes a template with common error handling
-func (h *WebHandler) executeTemplate(w http.ResponseWriter, templateName string, data interface{}) {
-	w.Header().Set("Content-Type", "text/html")
-	if err := h.templates.ExecuteTemplate(w, templateName, data); err != nil {
+// executeTemplate executes a template in the request's language with common error handling
+func (h *WebHandler) executeTemplate(w http.ResponseWriter, r *http.Request, templateName string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Vary", "Accept-Language")
+	if err := h.templates[negotiateLanguage(r)].ExecuteTemplate(w, templateName, data); err != nil {
 		log.Printf("Template error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
 
-// renderQueue renders the queue with positions calculated
-func (h *WebHandler) renderQueue(w http.ResponseWriter, templateName string) {
+// renderQueue renders the queue with positions and estimated waits calculated.
+// When completed items are collapsed they are left out of Items and only
+// counted, unless the request asks for ?expanded=1.
+func (h *WebHandler) renderQueue(w http.ResponseWriter, r *http.Request, templateName string) {
 	items := h.queue.GetAll()
-	positions := make(map[string]int)
+	positions := models.WaitingPositions(items, h.queue.TierWeights())
+	starts := models.EstimateStarts(items, h.queue.TierWeights(), h.queue.Machines(), h.queue.LoadMinutes(), time.Now())
+	waits := make(map[int]int)
+	for id, pos := range positions {
+		waits[pos] = minutesUntil(starts[id])
+	}
 
-	for _, item := range items {
-		if item.Status == models.StatusWaiting {
-			positions[item.ID] = len(positions) + 1
-		}
+	listed := items
+	completed := models.FilterByStatus(items, models.StatusCompleted)
+	collapsed := h.config.CollapseCompleted && r.URL.Query().Get("expanded") != "1" && len(completed) > 0
+	if collapsed {
+		listed = models.FilterByStatus(items, models.StatusWaiting, models.StatusInProgress, models.StatusTransit)
 	}
 
-	h.executeTemplate(w, templateName, struct {
-		Items     []*models.QueueItem
-		Positions map[string]int
-	}{items, positions})
+	h.executeTemplate(w, r, templateName, struct {
+		Items          []*models.QueueItem
+		Positions      map[string]int
+		Waits          map[int]int
+		AutoRemove     bool
+		Stagger        int
+		Collapsed      bool
+		CompletedCount int
+		Paused         bool
+		Machines       int
+	}{listed, positions, waits, h.queue.AutoRemoveEnabled(), h.queue.StaggerMinutes(0), collapsed, len(completed), h.queue.IsPaused(), h.queue.Machines()})
 }
 
-// Index serves the main page
+// Index serves the main page. As "/" matches every unregistered path, it
+// also answers those with NotFound.
 func (h *WebHandler) Index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		h.NotFound(w, r)
+		return
+	}
+
 	data := struct {
-		HasActiveLoad bool
-		Items         []*models.QueueItem
+		Items     []*models.QueueItem
+		TotalAdds int64
 	}{
-		HasActiveLoad: h.queue.HasActiveLoad(),
-		Items:         h.queue.GetAll(),
+		Items:     h.queue.GetAll(),
+		TotalAdds: h.queue.TotalAdds(),
+	}
+
+	h.executeTemplate(w, r, "index.html", data)
+}
+
+// NotFound renders the not-found page, or a JSON error for API paths
+func (h *WebHandler) NotFound(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeJSON(w, http.StatusNotFound, struct {
+			Error string `json:"error"`
+		}{"Not found"})
+		return
 	}
 
-	h.executeTemplate(w, "index.html", data)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Vary", "Accept-Language")
+	w.WriteHeader(http.StatusNotFound)
+	if err := h.templates[negotiateLanguage(r)].ExecuteTemplate(w, "404.html", r.URL.Path); err != nil {
+		log.Printf("Template error: %v", err)
+	}
 }
 
 // GetQueue returns the current queue as HTML
 func (h *WebHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
-	h.renderQueue(w, "queue.html")
+	h.renderQueue(w, r, "queue.html")
+}
+
+// printRow is a single load on the printable schedule
+type printRow struct {
+	Position int
+	Name     string
+	NumLoads int
+	Start    *time.Time
+	End      *time.Time
+}
+
+// PrintQueue serves a print-friendly schedule of running and upcoming loads
+func (h *WebHandler) PrintQueue(w http.ResponseWriter, r *http.Request) {
+	items := h.queue.GetAll()
+	now := time.Now()
+
+	running := make([]printRow, 0)
+	upcoming := make([]printRow, 0)
+	for _, item := range items {
+		if item.Status == models.StatusInProgress && item.StartTime != nil {
+			end := item.EndTime()
+			running = append(running, printRow{Name: item.Name, NumLoads: item.NumLoads, Start: item.StartTime, End: &end})
+		}
+	}
+	positions := models.WaitingPositions(items, h.queue.TierWeights())
+	starts := models.EstimateStarts(items, h.queue.TierWeights(), h.queue.Machines(), h.queue.LoadMinutes(), now)
+	for _, item := range models.FilterByStatus(items, models.StatusWaiting) {
+		pos := positions[item.ID]
+		start := starts[item.ID]
+		upcoming = append(upcoming, printRow{Position: pos, Name: item.Name, NumLoads: item.NumLoads, Start: &start})
+	}
+	sort.Slice(upcoming, func(i, j int) bool {
+		return upcoming[i].Position < upcoming[j].Position
+	})
+
+	h.executeTemplate(w, r, "print.html", struct {
+		GeneratedAt *time.Time
+		Running     []printRow
+		Upcoming    []printRow
+	}{&now, running, upcoming})
 }
 
 // GetForm returns the form HTML based on queue state
 func (h *WebHandler) GetForm(w http.ResponseWriter, r *http.Request) {
-	hasQueueItems := h.queue.HasQueueItems()
-	h.executeTemplate(w, "form.html", hasQueueItems)
+	h.executeTemplate(w, r, "form.html", struct {
+		MustQueue        bool
+		FreeMachines     int
+		Machines         int
+		DefaultNumLoads  int
+		AutoStartMinutes int
+		MaxDuration      int
+	}{h.mustQueue(), h.queue.AvailableMachineCount(), h.queue.Machines(), h.config.DefaultNumLoads, h.config.AutoStartMinutes, h.queue.MaxLoadMinutes()})
+}
+
+// mustQueue reports whether someone arriving now has to join the queue
+// rather than start right away, such as because every machine is taken or
+// others are already waiting for one
+func (h *WebHandler) mustQueue() bool {
+	ok, _ := h.queue.CanStart("")
+	return !ok
+}
+
+// parseDuration resolves a duration form value given either as minutes or as
+// a preset name, and checks it against the load cap
+func (h *WebHandler) parseDuration(value string) (int, error) {
+	duration, err := strconv.Atoi(value)
+	if err != nil && value != "" {
+		var ok bool
+		if duration, ok = h.config.ResolvePreset(value); !ok {
+			return 0, fmt.Errorf("Unknown duration preset %q", value)
+		}
+	}
+
+	if err := checkDuration(duration, h.queue.MaxLoadMinutes()); err != nil {
+		return 0, err
+	}
+	return duration, nil
+}
+
+// checkDuration rejects a timer length in minutes that is not positive or
+// exceeds max, where a max of 0 means there is no cap
+func checkDuration(duration, max int) error {
+	if duration <= 0 || (max > 0 && duration > max) {
+		if max > 0 {
+			return fmt.Errorf("Invalid duration (must be 1-%d minutes)", max)
+		}
+		return errors.New("Invalid duration")
+	}
+	return nil
 }
 
 // AddToQueue handles adding a new person to the queue
@@ -133,34 +283,87 @@ func (h *WebHandler) AddToQueue(w http.ResponseWriter, r *http.Request) {
 	}
 
 	name := r.FormValue("name")
-	if name == "" {
+	owners := models.ParseOwners(name)
+	if len(owners) == 0 {
 		http.Error(w, "Name is required", http.StatusBadRequest)
 		return
 	}
+	for _, owner := range owners {
+		if !h.config.IsAllowed(owner) {
+			http.Error(w, fmt.Sprintf("%s is not on the resident list", owner), http.StatusForbidden)
+			return
+		}
+	}
 
-	numLoads, err := strconv.Atoi(r.FormValue("num_loads"))
-	if err != nil || numLoads <= 0 || numLoads > 10 {
-		http.Error(w, "Invalid number of loads (must be 1-10)", http.StatusBadRequest)
+	numLoads := h.config.DefaultNumLoads
+	if numLoadsStr := r.FormValue("num_loads"); numLoadsStr != "" || numLoads == 0 {
+		var err error
+		numLoads, err = strconv.Atoi(numLoadsStr)
+		if err != nil || numLoads <= 0 || numLoads > MaxNumLoads {
+			http.Error(w, fmt.Sprintf("Invalid number of loads (must be 1-%d)", MaxNumLoads), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Anyone can post the form, so only staff may place a load in another
+	// tier; everyone else joins as a resident
+	tier := r.FormValue("tier")
+	if tier != "" && !models.IsValidTier(tier) {
+		http.Error(w, "Invalid tier", http.StatusBadRequest)
+		return
+	}
+	if tier == "" || !isAdmin(h.config.AdminToken, r) {
+		tier = models.TierResident
+	}
+
+	metadata := formMetadata(r)
+	if err := models.ValidateMetadata(metadata); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if h.queue.HasQueueItems() {
-		h.queue.AddToQueue(name, numLoads)
-	} else if durationStr := r.FormValue("duration"); durationStr != "" {
-		if duration, err := strconv.Atoi(durationStr); err == nil && duration > 0 {
-			h.queue.AddAndStart(name, duration, numLoads)
-		} else {
-			http.Error(w, "Invalid duration", http.StatusBadRequest)
+	// With a machine free and nobody waiting, giving a duration starts the
+	// load right away; without one the person is only queued and has to start
+	// their timer separately, unless AutoStartMinutes supplies a default.
+	durationStr := r.FormValue("duration")
+	if durationStr == "" && h.config.AutoStartMinutes > 0 {
+		durationStr = strconv.Itoa(h.config.AutoStartMinutes)
+	}
+
+	details := models.ItemDetails{Metadata: metadata, AssistedBy: r.FormValue("assisted_by")}
+	started := false
+	if !h.mustQueue() && durationStr != "" {
+		duration, err := h.parseDuration(durationStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// If the last free machine was taken since the check, queue instead
+		_, err = h.queue.AddAndStartWithDetails(name, duration, numLoads, tier, details)
+		started = err == nil
+	}
+	if !started {
+		if _, err := h.queue.AddToQueueWithDetails(name, numLoads, tier, details); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-	} else {
-		h.queue.AddToQueue(name, numLoads)
 	}
 
-	h.renderQueue(w, "queue.html")
+	h.renderQueue(w, r, "queue.html")
 }
 
-// StartTimer starts the timer for a queued person
+// formMetadata collects item metadata from "meta.<key>" form fields
+func formMetadata(r *http.Request) map[string]string {
+	metadata := make(map[string]string)
+	for field, values := range r.Form {
+		if key, ok := strings.CutPrefix(field, MetadataFieldPrefix); ok && len(values) > 0 {
+			metadata[key] = values[0]
+		}
+	}
+	return metadata
+}
+
+// StartTimer starts the timer for a queued person, optionally after a short delay
 func (h *WebHandler) StartTimer(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -173,18 +376,225 @@ func (h *WebHandler) StartTimer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	duration, err := strconv.Atoi(r.FormValue("duration"))
-	if err != nil || duration <= 0 {
-		http.Error(w, "Invalid duration", http.StatusBadRequest)
+	duration, err := h.parseDuration(r.FormValue("duration"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	delay := 0
+	if delayStr := r.FormValue("delay"); delayStr != "" {
+		delay, err = strconv.Atoi(delayStr)
+		if err != nil || delay < 0 || delay > MaxStartDelayMinutes {
+			http.Error(w, fmt.Sprintf("Invalid delay (must be 0-%d minutes)", MaxStartDelayMinutes), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.queue.StartTimerDelayed(id, duration, delay); err != nil {
+		status, message := startErrorResponse(err)
+		if errors.Is(err, models.ErrStartTooSoon) {
+			message += fmt.Sprintf(" (try a delay of %d minutes)", delay+h.queue.StaggerMinutes(delay))
+		}
+		http.Error(w, message, status)
+		return
+	}
+
+	h.renderQueue(w, r, "queue.html")
+}
+
+// startErrorResponse maps a StartTimer error to a status code and user-facing
+// message, which for a load the queue's start rules refused is their reason
+func startErrorResponse(err error) (int, string) {
+	var refused *models.StartError
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound, "Item not found"
+	case errors.Is(err, models.ErrAlreadyCompleted):
+		return http.StatusConflict, "That load already finished"
+	case errors.Is(err, models.ErrNotWaiting):
+		return http.StatusConflict, "That load is already running"
+	case errors.Is(err, models.ErrStartTooSoon):
+		return http.StatusConflict, "Another load started moments ago; please delay your start"
+	case errors.Is(err, models.ErrNoFreeMachine):
+		return http.StatusConflict, "Every machine is in use"
+	case errors.Is(err, models.ErrNotAllowed) && errors.As(err, &refused):
+		return http.StatusForbidden, refused.Reason
+	case errors.As(err, &refused):
+		return http.StatusConflict, refused.Reason
+	default:
+		return http.StatusBadRequest, "Could not start timer"
+	}
+}
+
+// MoveToPosition lets staff move a waiting item to the waiting position in
+// the "position" form value, such as to fix the order after someone cut in line
+func (h *WebHandler) MoveToPosition(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Path[len("/api/queue/move/"):]
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	position, err := strconv.Atoi(r.FormValue("position"))
+	if err != nil || position < 1 {
+		http.Error(w, "Invalid position", http.StatusBadRequest)
+		return
+	}
+	if !h.queue.MoveToPosition(id, position) {
+		http.Error(w, "Can't move that item to that position", http.StatusConflict)
+		return
+	}
+
+	h.renderQueue(w, r, "queue.html")
+}
+
+// CompleteNow marks a running load done before its timer runs out
+func (h *WebHandler) CompleteNow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Path[len("/api/queue/complete/"):]
+	if !h.queue.CompleteNow(id) {
+		http.Error(w, "That load isn't running", http.StatusConflict)
+		return
+	}
+
+	h.renderQueue(w, r, "queue.html")
+}
+
+// ShortenTimer cuts a running load's remaining time to the "minutes" form
+// value; zero marks it done
+func (h *WebHandler) ShortenTimer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Path[len("/api/queue/shorten/"):]
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	remaining, err := strconv.Atoi(r.FormValue("minutes"))
+	if err != nil || remaining < 0 {
+		http.Error(w, "Invalid minutes", http.StatusBadRequest)
+		return
+	}
+	if !h.queue.ShortenTimer(id, remaining) {
+		http.Error(w, "That load isn't running or already ends sooner", http.StatusConflict)
+		return
+	}
+
+	h.renderQueue(w, r, "queue.html")
+}
+
+// CancelStart cancels a delayed start before it begins
+func (h *WebHandler) CancelStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Path[len("/api/queue/cancel-start/"):]
+	if !h.queue.CancelDelayedStart(id) {
+		http.Error(w, "No delayed start to cancel", http.StatusConflict)
+		return
+	}
+
+	h.renderQueue(w, r, "queue.html")
+}
+
+// StartDrying starts the dryer timer for a wash that is in transit
+func (h *WebHandler) StartDrying(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Path[len("/api/queue/dry/"):]
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	duration, err := h.parseDuration(r.FormValue("duration"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.queue.StartDrying(id, duration) {
+		http.Error(w, "Could not start dryer", http.StatusBadRequest)
+		return
+	}
+
+	h.renderQueue(w, r, "queue.html")
+}
+
+// Requeue queues another load for the owners of a completed item
+func (h *WebHandler) Requeue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Path[len("/api/queue/requeue/"):]
+	if _, ok := h.queue.Requeue(id); !ok {
+		http.Error(w, "Only a finished load can be requeued", http.StatusBadRequest)
+		return
+	}
+
+	h.renderQueue(w, r, "queue.html")
+}
+
+// UpdateItem corrects the name and number of loads of a queued item from the
+// "name" and "num_loads" form values, keeping its place in line
+func (h *WebHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Path[len("/api/queue/"):]
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := r.FormValue("name")
+	owners := models.ParseOwners(name)
+	if len(owners) == 0 {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	for _, owner := range owners {
+		if !h.config.IsAllowed(owner) {
+			http.Error(w, fmt.Sprintf("%s is not on the resident list", owner), http.StatusForbidden)
+			return
+		}
+	}
+
+	numLoads, err := strconv.Atoi(r.FormValue("num_loads"))
+	if err != nil || numLoads <= 0 || numLoads > MaxNumLoads {
+		http.Error(w, fmt.Sprintf("Invalid number of loads (must be 1-%d)", MaxNumLoads), http.StatusBadRequest)
 		return
 	}
 
-	if !h.queue.StartTimer(id, duration) {
-		http.Error(w, "Could not start timer", http.StatusBadRequest)
+	if !h.queue.UpdateItem(id, name, numLoads) {
+		http.Error(w, "That item can't be changed", http.StatusConflict)
 		return
 	}
 
-	h.renderQueue(w, "queue.html")
+	h.renderQueue(w, r, "queue.html")
 }
 
 // RemoveFromQueue removes a person from the queue
@@ -205,5 +615,5 @@ func (h *WebHandler) RemoveFromQueue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.renderQueue(w, "queue.html")
+	h.renderQueue(w, r, "queue.html")
 }
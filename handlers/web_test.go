@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"laundry-scheduler/config"
+	"laundry-scheduler/models"
+)
+
+// TestMain runs the tests from the project root, where the templates are
+func TestMain(m *testing.M) {
+	if err := os.Chdir(".."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestHandlers returns a fresh queue with web and API handlers for cfg
+func newTestHandlers(t *testing.T, cfg *config.Config) (*models.LaundryQueue, *WebHandler, *APIHandler) {
+	t.Helper()
+	queue := models.NewLaundryQueueWithOptions(cfg.QueueOptions())
+	t.Cleanup(queue.Close)
+	return queue, NewWebHandler(queue, cfg), NewAPIHandler(queue, cfg)
+}
+
+// postForm sends form values to handler as a POST and returns the response
+func postForm(handler http.HandlerFunc, path string, form url.Values, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestAddToQueueTierNeedsAdmin(t *testing.T) {
+	queue, web, _ := newTestHandlers(t, &config.Config{AdminToken: "secret"})
+	if _, err := queue.AddAndStart("Runner", 30, 1, models.TierResident); err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"name": {"Sam"}, "num_loads": {"1"}, "tier": {models.TierStaff}}
+	if rec := postForm(web.AddToQueue, "/api/queue/add", form, nil); rec.Code != http.StatusOK {
+		t.Fatalf("anonymous add: status %d", rec.Code)
+	}
+	form.Set("name", "Sally")
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+	if rec := postForm(web.AddToQueue, "/api/queue/add", form, admin); rec.Code != http.StatusOK {
+		t.Fatalf("admin add: status %d", rec.Code)
+	}
+
+	tiers := make(map[string]string)
+	for _, item := range queue.GetAll() {
+		tiers[item.Name] = item.Tier
+	}
+	if tiers["Sam"] != models.TierResident {
+		t.Errorf("anonymous staff tier request gave tier %q, want resident", tiers["Sam"])
+	}
+	if tiers["Sally"] != models.TierStaff {
+		t.Errorf("admin staff tier request gave tier %q, want staff", tiers["Sally"])
+	}
+}
+
+func TestFormDurationMaxFollowsLoadCap(t *testing.T) {
+	for _, tt := range []struct {
+		cap  time.Duration
+		want string
+	}{
+		{90 * time.Minute, `max="90"`},
+		{0, ""},
+	} {
+		_, web, _ := newTestHandlers(t, &config.Config{MaxLoadDuration: tt.cap})
+		rec := httptest.NewRecorder()
+		web.GetForm(rec, httptest.NewRequest(http.MethodGet, "/api/form", nil))
+
+		input := rec.Body.String()
+		input = input[strings.Index(input, `id="duration"`):]
+		input = input[:strings.Index(input, ">")]
+		if tt.want == "" && strings.Contains(input, "max=") {
+			t.Errorf("uncapped duration input has a max: %s", input)
+		}
+		if tt.want != "" && !strings.Contains(input, tt.want) {
+			t.Errorf("duration input %s, want %s", input, tt.want)
+		}
+	}
+}
+
+func TestQueueRendersInRequestedLanguage(t *testing.T) {
+	queue, web, _ := newTestHandlers(t, &config.Config{})
+	if _, err := queue.AddAndStart("Runner", 30, 1, models.TierResident); err != nil {
+		t.Fatal(err)
+	}
+	queue.AddToQueue("Ann", 1, models.TierResident)
+
+	for lang, labels := range map[string][]string{
+		"es-ES,es;q=0.9": {catalogs["es"]["status.waiting"], catalogs["es"]["status.in_progress"]},
+		"en-US":          {catalogs["en"]["status.waiting"], catalogs["en"]["status.in_progress"]},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/queue", nil)
+		req.Header.Set("Accept-Language", lang)
+		rec := httptest.NewRecorder()
+		web.GetQueue(rec, req)
+
+		for _, label := range labels {
+			if !strings.Contains(rec.Body.String(), label) {
+				t.Errorf("Accept-Language %s: queue is missing %q", lang, label)
+			}
+		}
+	}
+}
+
+func TestStartErrorsHaveDistinctMessages(t *testing.T) {
+	seen := make(map[string]error)
+	for _, err := range []error{models.ErrNotFound, models.ErrAlreadyCompleted, models.ErrNotWaiting, models.ErrNoFreeMachine, models.ErrStartTooSoon} {
+		_, message := startErrorResponse(err)
+		if other, ok := seen[message]; ok {
+			t.Errorf("%v and %v share the message %q", err, other, message)
+		}
+		seen[message] = err
+	}
+	if _, message := startErrorResponse(models.ErrAlreadyCompleted); !strings.Contains(message, "finished") {
+		t.Errorf("completed load message %q doesn't say it finished", message)
+	}
+}
+
+func TestPrintQueueListsLoadsWithoutControls(t *testing.T) {
+	queue, web, _ := newTestHandlers(t, &config.Config{})
+	if _, err := queue.AddAndStart("Runner", 30, 1, models.TierResident); err != nil {
+		t.Fatal(err)
+	}
+	queue.AddToQueue("Ann", 2, models.TierResident)
+
+	rec := httptest.NewRecorder()
+	web.PrintQueue(rec, httptest.NewRequest(http.MethodGet, "/api/queue/print", nil))
+	page := rec.Body.String()
+
+	for _, want := range []string{"Running now", "<td>Runner</td>", "<td>1</td><td>Ann</td><td>2</td>"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("printable schedule is missing %q", want)
+		}
+	}
+	for _, control := range []string{"<button", "<form", "hx-"} {
+		if strings.Contains(page, control) {
+			t.Errorf("printable schedule contains %q", control)
+		}
+	}
+}
+
+func TestAddToQueueChecksAllowlist(t *testing.T) {
+	queue, web, _ := newTestHandlers(t, &config.Config{Allowlist: []string{"Ann", "Bob"}})
+
+	tests := []struct {
+		name string
+		code int
+	}{
+		{"ann", http.StatusOK},
+		{"Mallory", http.StatusForbidden},
+		{"Bob, Mallory", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		form := url.Values{"name": {tt.name}, "num_loads": {"1"}}
+		if rec := postForm(web.AddToQueue, "/api/queue/add", form, nil); rec.Code != tt.code {
+			t.Errorf("adding %q: status %d, want %d", tt.name, rec.Code, tt.code)
+		}
+	}
+	if len(queue.GetAll()) != 1 {
+		t.Errorf("queue has %d items, want only the allowed one", len(queue.GetAll()))
+	}
+}
+
+func TestAddToQueueDefaultNumLoads(t *testing.T) {
+	queue, web, _ := newTestHandlers(t, &config.Config{DefaultNumLoads: 2})
+
+	if rec := postForm(web.AddToQueue, "/api/queue/add", url.Values{"name": {"Ann"}}, nil); rec.Code != http.StatusOK {
+		t.Fatalf("add without num_loads: status %d", rec.Code)
+	}
+	if items := queue.GetAll(); len(items) != 1 || items[0].NumLoads != 2 {
+		t.Errorf("add without num_loads queued %+v, want 2 loads", items)
+	}
+
+	for _, loads := range []string{"0", "11", "lots"} {
+		form := url.Values{"name": {"Bob"}, "num_loads": {loads}}
+		if rec := postForm(web.AddToQueue, "/api/queue/add", form, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("num_loads %s: status %d, want 400", loads, rec.Code)
+		}
+	}
+}
+
+func TestUpdateItemKeepsPlaceInLine(t *testing.T) {
+	queue, web, _ := newTestHandlers(t, &config.Config{Allowlist: []string{"Ann", "Bob", "Cat", "Dan"}})
+	if _, err := queue.AddAndStart("Dan", 30, 1, models.TierResident); err != nil {
+		t.Fatal(err)
+	}
+	ann := queue.AddToQueue("Ann", 1, models.TierResident)
+	queue.AddToQueue("Bob", 1, models.TierResident)
+
+	update := func(id string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/queue/"+id, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		web.UpdateItem(rec, req)
+		return rec
+	}
+
+	if rec := update(ann.ID, url.Values{"name": {"Ann, Cat"}, "num_loads": {"3"}}); rec.Code != http.StatusOK {
+		t.Fatalf("valid update: status %d", rec.Code)
+	}
+	items := queue.GetAll()
+	positions := models.WaitingPositions(items, queue.TierWeights())
+	for _, item := range items {
+		if item.ID != ann.ID {
+			continue
+		}
+		if item.Name != "Ann, Cat" || item.NumLoads != 3 || !item.QueuedAt.Equal(ann.QueuedAt) || positions[item.ID] != 1 {
+			t.Errorf("updated %q with %d loads at position %d, want Ann, Cat with 3 still first in line",
+				item.Name, item.NumLoads, positions[item.ID])
+		}
+	}
+
+	for _, tt := range []struct {
+		name, loads string
+		code        int
+	}{
+		{"Ann", "0", http.StatusBadRequest},
+		{"Ann", "11", http.StatusBadRequest},
+		{"Ann", "lots", http.StatusBadRequest},
+		{"", "1", http.StatusBadRequest},
+		{"Ann, Mallory", "1", http.StatusForbidden},
+	} {
+		form := url.Values{"name": {tt.name}, "num_loads": {tt.loads}}
+		if rec := update(ann.ID, form); rec.Code != tt.code {
+			t.Errorf("name %q, loads %s: status %d, want %d", tt.name, tt.loads, rec.Code, tt.code)
+		}
+	}
+	if rec := update("missing", url.Values{"name": {"Ann"}, "num_loads": {"1"}}); rec.Code != http.StatusConflict {
+		t.Errorf("unknown item: status %d, want 409", rec.Code)
+	}
+}
+
+func TestParseDurationPresets(t *testing.T) {
+	_, web, _ := newTestHandlers(t, &config.Config{
+		DurationPresets: map[string]int{"quick": 30, "heavy": 75},
+		MaxLoadDuration: time.Hour,
+	})
+
+	tests := []struct {
+		value string
+		want  int
+		ok    bool
+	}{
+		{"quick", 30, true},
+		{" Quick ", 30, true},
+		{"45", 45, true},
+		{"heavy", 0, false},
+		{"90", 0, false},
+		{"delicate", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := web.parseDuration(tt.value)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseDuration(%q) = %d, %v; want %d, ok %v", tt.value, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAddToQueueWithFreeMachine(t *testing.T) {
+	tests := []struct {
+		name      string
+		autoStart int
+		duration  string
+		status    string
+	}{
+		{"with duration", 0, "45", models.StatusInProgress},
+		{"without duration", 0, "", models.StatusWaiting},
+		{"without duration, auto start", 40, "", models.StatusInProgress},
+	}
+	for _, tt := range tests {
+		queue, web, _ := newTestHandlers(t, &config.Config{AutoStartMinutes: tt.autoStart})
+		form := url.Values{"name": {"Ann"}, "num_loads": {"1"}, "duration": {tt.duration}}
+		if rec := postForm(web.AddToQueue, "/api/queue/add", form, nil); rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", tt.name, rec.Code)
+		}
+		if items := queue.GetAll(); len(items) != 1 || items[0].Status != tt.status {
+			t.Errorf("%s: queue %+v, want one %s item", tt.name, items, tt.status)
+		}
+	}
+}
+
+func TestCompleteNowOnlyFinishesRunningLoads(t *testing.T) {
+	queue, web, _ := newTestHandlers(t, &config.Config{Machines: 2, DisableAutoRemove: true})
+	running, err := queue.AddAndStart("Runner", 30, 1, models.TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := queue.AddAndStart("Done", 30, 1, models.TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.CompleteNow(done.ID)
+	waiting := queue.AddToQueue("Waiting", 1, models.TierResident)
+
+	rec := postForm(web.CompleteNow, "/api/queue/complete/"+running.ID, nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("completing a running load: status %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "Waiting") {
+		t.Errorf("response doesn't re-render the queue:\n%s", rec.Body.String())
+	}
+	for _, item := range queue.GetAll() {
+		if item.ID == running.ID && item.Status != models.StatusCompleted {
+			t.Errorf("load is %s after completing it, want completed", item.Status)
+		}
+	}
+
+	for name, id := range map[string]string{"waiting": waiting.ID, "completed": done.ID, "unknown": "missing"} {
+		if rec := postForm(web.CompleteNow, "/api/queue/complete/"+id, nil, nil); rec.Code != http.StatusConflict {
+			t.Errorf("%s item: status %d, want 409", name, rec.Code)
+		}
+	}
+}
+
+func TestQueueCollapsesCompletedLoads(t *testing.T) {
+	queue, web, _ := newTestHandlers(t, &config.Config{Machines: 4, CollapseCompleted: true, DisableAutoRemove: true})
+	for _, name := range []string{"Done1", "Done2", "Done3"} {
+		item, err := queue.AddAndStart(name, 30, 1, models.TierResident)
+		if err != nil {
+			t.Fatal(err)
+		}
+		queue.CompleteNow(item.ID)
+	}
+	if _, err := queue.AddAndStart("Runner", 30, 1, models.TierResident); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) string {
+		rec := httptest.NewRecorder()
+		web.GetQueue(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Body.String()
+	}
+
+	body := get("/api/queue")
+	if !strings.Contains(body, "3 finished recently") {
+		t.Errorf("collapsed queue does not count 3 completed loads:\n%s", body)
+	}
+	if strings.Contains(body, "Done1") || !strings.Contains(body, "Runner") {
+		t.Errorf("collapsed queue should list only the running load:\n%s", body)
+	}
+
+	expanded := get("/api/queue?expanded=1")
+	for _, name := range []string{"Done1", "Done2", "Done3", "Runner"} {
+		if !strings.Contains(expanded, name) {
+			t.Errorf("expanded queue is missing %s", name)
+		}
+	}
+	if strings.Contains(expanded, "finished recently") {
+		t.Error("expanded queue still shows the collapsed count")
+	}
+}
+
+func TestAssistedLoadShowsResidentAndStaff(t *testing.T) {
+	queue, web, _ := newTestHandlers(t, &config.Config{})
+	events := make(chan models.Event, 8)
+	unsubscribe := queue.Events().Subscribe(func(e models.Event) { events <- e }, models.EventTimerStarted, models.EventItemCompleted)
+	defer unsubscribe()
+
+	form := url.Values{"name": {"Ann"}, "num_loads": {"1"}, "duration": {"45"}, "assisted_by": {"Sam"}}
+	rec := postForm(web.AddToQueue, "/api/queue/add", form, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("add: status %d: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "<h3>Ann ") || !strings.Contains(body, "Service: Sam") {
+		t.Errorf("assisted load does not show Ann and Sam:\n%s", body)
+	}
+
+	items := queue.GetAll()
+	if len(items) != 1 || items[0].Name != "Ann" || items[0].AssistedBy != "Sam" {
+		t.Fatalf("queue = %+v, want Ann's load assisted by Sam", items)
+	}
+	queue.CompleteNow(items[0].ID)
+
+	for _, want := range []models.EventType{models.EventTimerStarted, models.EventItemCompleted} {
+		select {
+		case e := <-events:
+			if e.Type != want || e.Item.Name != "Ann" || e.Item.AssistedBy != "Sam" {
+				t.Errorf("event %s for %q by %q, want %s for Ann by Sam", e.Type, e.Item.Name, e.Item.AssistedBy, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s event", want)
+		}
+	}
+}
+
+// fixedID is an ID generator that always proposes the same ID
+type fixedID string
+
+func (id fixedID) Next(*models.QueueItem) string { return string(id) }
+
+func TestQueueEscapesUserInput(t *testing.T) {
+	const hostile = `<script>alert("x")</script>`
+	queue := models.NewLaundryQueueWithOptions(models.Options{IDGenerator: fixedID(`a"b/../<c>`)})
+	t.Cleanup(queue.Close)
+	web := NewWebHandler(queue, &config.Config{})
+
+	if _, err := queue.AddAndStartWithDetails(hostile, 30, 1, models.TierResident, models.ItemDetails{AssistedBy: hostile}); err != nil {
+		t.Fatal(err)
+	}
+	queue.AddToQueue(hostile, 1, models.TierResident)
+
+	for path, handler := range map[string]http.HandlerFunc{"/api/queue": web.GetQueue, "/api/queue/print": web.PrintQueue} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		body := rec.Body.String()
+		if strings.Contains(body, "<script>alert") {
+			t.Errorf("%s renders the name unescaped:\n%s", path, body)
+		}
+		if !strings.Contains(body, "&lt;script&gt;") {
+			t.Errorf("%s does not show the escaped name:\n%s", path, body)
+		}
+		if strings.Contains(body, `a"b`) || strings.Contains(body, "<c>") {
+			t.Errorf("%s puts the ID in a URL unescaped:\n%s", path, body)
+		}
+	}
+}
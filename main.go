@@ -1,33 +1,142 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"laundry-scheduler/config"
 	"laundry-scheduler/handlers"
 	"laundry-scheduler/models"
 )
 
+// shutdownTimeout is how long in-flight requests get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func main() {
-	queue := models.NewLaundryQueue()
-	webHandler := handlers.NewWebHandler(queue)
+	cfg := config.Load()
+	queue := models.NewLaundryQueueWithOptions(cfg.QueueOptions())
+	queue.Events().Subscribe(func(e models.Event) {
+		log.Printf("Staff alert: machine is free but %s, first in line, hasn't started", e.Item.Name)
+	}, models.EventMachineIdle)
+	queue.Events().Subscribe(func(e models.Event) {
+		log.Printf("Staff alert: %s may have left the queue", e.Item.Name)
+	}, models.EventPossiblyAbsent)
+	queue.Events().Subscribe(func(e models.Event) {
+		if e.Item.AssistedBy != "" {
+			log.Printf("Service: %s %s for %s", e.Item.AssistedBy, e.Type, e.Item.Name)
+		}
+	}, models.EventTimerStarted, models.EventItemCompleted)
+	queue.Events().Subscribe(func(e models.Event) {
+		log.Printf("Notify: the machine is free and it's %s's turn", e.Item.Name)
+	}, models.EventNextUp)
+	queue.Events().Subscribe(func(e models.Event) {
+		log.Printf("Reminder: %s's load starts at %s unless cancelled", e.Item.Name, e.Item.StartTime.Format("3:04 PM"))
+	}, models.EventStartingSoon)
+	webHandler := handlers.NewWebHandler(queue, cfg)
+	apiHandler := handlers.NewAPIHandler(queue, cfg)
+
+	metrics := handlers.NewRequestMetrics(cfg.SlowRequestAfter)
 
-	setupRoutes(webHandler)
+	setupRoutes(webHandler, apiHandler, metrics, cfg.AdminToken)
 	setupStaticFiles()
 
-	port := handlers.DefaultPort
-	log.Printf("Server starting on http://localhost%s", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	server := &http.Server{
+		Addr:    handlers.DefaultPort,
+		Handler: handlers.Recover(handlers.NoSniff(metrics.Track(http.DefaultServeMux))),
+	}
+	go func() {
+		if err := serve(server, cfg); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	log.Printf("Received %s, shutting down", <-signals)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	queue.Close()
 }
 
-func setupRoutes(handler *handlers.WebHandler) {
+// serve runs server over HTTPS when a certificate and key are configured,
+// redirecting plain HTTP on cfg.RedirectAddr to it, or over plain HTTP otherwise
+func serve(server *http.Server, cfg *config.Config) error {
+	if !cfg.TLSEnabled() {
+		log.Printf("Server starting on http://localhost%s", server.Addr)
+		return server.ListenAndServe()
+	}
+
+	if cfg.RedirectAddr != "" {
+		go func() {
+			log.Printf("Redirecting http://localhost%s to HTTPS", cfg.RedirectAddr)
+			log.Fatal(http.ListenAndServe(cfg.RedirectAddr, handlers.RedirectToHTTPS(server.Addr)))
+		}()
+	}
+	log.Printf("Server starting on https://localhost%s", server.Addr)
+	return server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+}
+
+func setupRoutes(handler *handlers.WebHandler, api *handlers.APIHandler, metrics *handlers.RequestMetrics, adminToken string) {
 	http.HandleFunc("/", handler.Index)
 	http.HandleFunc("/api/queue", handler.GetQueue)
 	http.HandleFunc("/api/form", handler.GetForm)
 	http.HandleFunc("/api/queue/add", handler.AddToQueue)
 	http.HandleFunc("/api/queue/start/", handler.StartTimer)
-	http.HandleFunc("/api/queue/", handler.RemoveFromQueue)
+	http.HandleFunc("/api/queue/cancel-start/", handler.CancelStart)
+	http.HandleFunc("/api/queue/complete/", handler.CompleteNow)
+	http.HandleFunc("/api/queue/shorten/", handler.ShortenTimer)
+	http.HandleFunc("/api/queue/dry/", handler.StartDrying)
+	http.HandleFunc("/api/queue/requeue/", handler.Requeue)
+	http.HandleFunc("/api/queue/move/", handlers.RequireAdmin(adminToken, handler.MoveToPosition))
+	http.HandleFunc("/api/queue/forecast", api.GetForecast)
+	http.HandleFunc("/api/queue/text", api.GetQueueText)
+	http.HandleFunc("/api/queue/table", api.GetQueueTable)
+	http.HandleFunc("/api/queue/summary", api.GetSummary)
+	http.HandleFunc("/api/queue/wait-estimate", api.GetWaitEstimate)
+	http.HandleFunc("/api/queue/next", api.GetNextUp)
+	http.HandleFunc("/api/queue/can-start", api.GetCanStart)
+	http.HandleFunc("/api/queue/added", api.GetAddedBetween)
+	http.HandleFunc("/api/queue/print", handler.PrintQueue)
+	http.HandleFunc("/api/queue/recently-freed", api.GetRecentlyFreed)
+	http.HandleFunc("/api/queue/expiring-soon", api.GetExpiringSoon)
+	http.HandleFunc("/api/queue/at", api.GetStateAt)
+	http.HandleFunc("/api/queue/remove-by-name", handlers.RequireAdmin(adminToken, api.RemoveByName))
+	http.HandleFunc("/api/queue/import-roster", handlers.RequireAdmin(adminToken, api.ImportRoster))
+	http.HandleFunc("/api/queue/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, ".ics"):
+			api.GetItemCalendar(w, r)
+		case r.Method == http.MethodPut:
+			handler.UpdateItem(w, r)
+		case r.Method == http.MethodDelete:
+			handler.RemoveFromQueue(w, r)
+		default:
+			handler.NotFound(w, r)
+		}
+	})
+
+	http.HandleFunc("/api/json/queue", api.GetQueue)
+	http.HandleFunc("/api/json/queue/start/", api.StartTimer)
+	http.HandleFunc("/api/json/queue/batch", api.GetBatch)
+	http.HandleFunc("/api/json/history", api.GetHistory)
+	http.HandleFunc("/api/capabilities", api.GetCapabilities)
+
+	http.HandleFunc("/api/admin/complete-expired", handlers.RequireAdmin(adminToken, api.CompleteExpired))
+	http.HandleFunc("/api/admin/pause", handlers.RequireAdmin(adminToken, api.PauseAll))
+	http.HandleFunc("/api/admin/resume", handlers.RequireAdmin(adminToken, api.ResumeAll))
+	http.HandleFunc("/api/config", handlers.RequireAdmin(adminToken, api.GetConfig))
+	http.HandleFunc("/api/debug/slow", handlers.RequireAdmin(adminToken, metrics.GetSlow))
 }
 
 func setupStaticFiles() {
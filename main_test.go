@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"laundry-scheduler/config"
+	"laundry-scheduler/handlers"
+	"laundry-scheduler/models"
+)
+
+var (
+	routesOnce sync.Once
+	// testQueue backs the routes every test in this file shares, as they can
+	// only be registered on the default mux once
+	testQueue *models.LaundryQueue
+)
+
+// routedQueue registers the application's routes with the admin token
+// "secret" on first use and returns the queue behind them
+func routedQueue() *models.LaundryQueue {
+	routesOnce.Do(func() {
+		cfg := &config.Config{AdminToken: "secret"}
+		testQueue = models.NewLaundryQueue()
+		setupRoutes(handlers.NewWebHandler(testQueue, cfg), handlers.NewAPIHandler(testQueue, cfg), handlers.NewRequestMetrics(0), cfg.AdminToken)
+	})
+	return testQueue
+}
+
+// serveRoute sends a request through the application's routes
+func serveRoute(method, path string) *httptest.ResponseRecorder {
+	routedQueue()
+	rec := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestStaffRoutesRequireAdmin(t *testing.T) {
+	waiting := routedQueue().AddToQueue("Ann", 1, models.TierResident)
+
+	routes := []struct {
+		method, path string
+	}{
+		{http.MethodPost, "/api/queue/move/" + waiting.ID + "?position=1"},
+		{http.MethodDelete, "/api/queue/remove-by-name?name=Ann"},
+		{http.MethodPost, "/api/queue/import-roster"},
+		{http.MethodPost, "/api/admin/pause"},
+		{http.MethodGet, "/api/debug/slow"},
+	}
+	for _, route := range routes {
+		if rec := serveRoute(route.method, route.path); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without a token: status %d, want %d", route.method, route.path, rec.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestUnknownItemRouteIsJSONNotFound(t *testing.T) {
+	rec := serveRoute(http.MethodGet, "/api/queue/no-such-item")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error == "" {
+		t.Errorf("body is not a JSON error: %v", err)
+	}
+}
+
+func TestUnknownRoutesAreNotFound(t *testing.T) {
+	page := serveRoute(http.MethodGet, "/foo")
+	if page.Code != http.StatusNotFound {
+		t.Errorf("/foo: status %d, want %d", page.Code, http.StatusNotFound)
+	}
+	if ct := page.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("/foo: content type %q, want HTML", ct)
+	}
+	if !strings.Contains(page.Body.String(), "/foo") {
+		t.Error("/foo: not-found page does not mention the path")
+	}
+
+	api := serveRoute(http.MethodGet, "/api/foo")
+	if api.Code != http.StatusNotFound {
+		t.Errorf("/api/foo: status %d, want %d", api.Code, http.StatusNotFound)
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(api.Body).Decode(&body); err != nil || body.Error == "" {
+		t.Errorf("/api/foo: body is not a JSON error: %v", err)
+	}
+}
+
+func TestServeSelectsTLSWithCertAndKey(t *testing.T) {
+	cert := filepath.Join(t.TempDir(), "missing-cert.pem")
+	cfg := &config.Config{TLSCertFile: cert, TLSKeyFile: cert}
+	server := &http.Server{Addr: "127.0.0.1:0"}
+
+	// Plain HTTP would serve until closed; TLS fails at once on the missing cert
+	done := make(chan error, 1)
+	go func() { done <- serve(server, cfg) }()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), cert) {
+			t.Errorf("serve returned %v, want an error loading %s", err, cert)
+		}
+	case <-time.After(5 * time.Second):
+		server.Close()
+		t.Fatal("serve did not try to load the certificate")
+	}
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// EventType identifies a kind of queue state change
+type EventType string
+
+const (
+	// EventItemAdded is published when someone joins the queue
+	EventItemAdded EventType = "item_added"
+	// EventItemUpdated is published when an item changes without a more
+	// specific event, such as a corrected name, a new place in line or a
+	// countdown paused or resumed
+	EventItemUpdated EventType = "item_updated"
+	// EventTimerStarted is published when a load's timer starts
+	EventTimerStarted EventType = "timer_started"
+	// EventItemInTransit is published when a wash finishes and is waiting to
+	// be moved to a dryer
+	EventItemInTransit EventType = "item_in_transit"
+	// EventItemCompleted is published when a load is marked completed
+	EventItemCompleted EventType = "item_completed"
+	// EventItemRemoved is published when an item leaves the queue
+	EventItemRemoved EventType = "item_removed"
+	// EventStartingSoon is published shortly before a delayed start begins,
+	// giving the owner a chance to cancel it
+	EventStartingSoon EventType = "starting_soon"
+	// EventMachineIdle is published when the machine has sat free too long
+	// while people wait; the item is the person at the front of the line
+	EventMachineIdle EventType = "machine_idle"
+	// EventPossiblyAbsent is published when the front of the line is flagged
+	// as possibly absent after leaving a free machine unused
+	EventPossiblyAbsent EventType = "possibly_absent"
+	// EventNextUp is published once per item when a free machine is ready
+	// for it, so several items are announced when several machines free up
+	EventNextUp EventType = "next_up"
+
+	// subscriberBuffer is how many undelivered events a subscriber may fall behind by
+	subscriberBuffer = 64
+)
+
+// Event describes a queue state change. Item is a snapshot taken when the
+// event was published, so later changes to the live item don't affect it.
+type Event struct {
+	Type EventType `json:"type"`
+	Item QueueItem `json:"item"`
+	At   time.Time `json:"at"`
+}
+
+// subscriber receives events of the types it registered for
+type subscriber struct {
+	types   map[EventType]bool
+	handler func(Event)
+	events  chan Event
+}
+
+// EventBus fans queue events out to subscribers. Publishing never blocks:
+// each subscriber has its own buffered channel and goroutine, and events are
+// dropped with a warning if a subscriber falls too far behind.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[*subscriber]bool
+}
+
+// NewEventBus creates an event bus with no subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[*subscriber]bool)}
+}
+
+// Subscribe registers handler for the given event types, or for every event if
+// none are given. Handlers run on their own goroutine, in publish order, and a
+// panicking handler is logged without affecting other subscribers. The returned
+// function unsubscribes.
+func (b *EventBus) Subscribe(handler func(Event), types ...EventType) func() {
+	sub := &subscriber{
+		types:   make(map[EventType]bool),
+		handler: handler,
+		events:  make(chan Event, subscriberBuffer),
+	}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = true
+	b.mu.Unlock()
+
+	go sub.run()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, sub)
+			b.mu.Unlock()
+			close(sub.events)
+		})
+	}
+}
+
+// Publish delivers an event to every interested subscriber without blocking
+func (b *EventBus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if len(sub.types) > 0 && !sub.types[event.Type] {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			log.Printf("Warning: event subscriber is behind, dropping %s event", event.Type)
+		}
+	}
+}
+
+func (s *subscriber) run() {
+	for event := range s.events {
+		s.deliver(event)
+	}
+}
+
+func (s *subscriber) deliver(event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Event subscriber panicked handling %s: %v", event.Type, r)
+		}
+	}()
+	s.handler(event)
+}
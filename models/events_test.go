@@ -0,0 +1,87 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEveryChangeIsPublishedInOrder(t *testing.T) {
+	q := NewLaundryQueueWithMachines(2)
+	defer q.Close()
+
+	events := make(chan Event, subscriberBuffer)
+	unsubscribe := q.Events().Subscribe(func(event Event) { events <- event })
+	defer unsubscribe()
+
+	running, err := q.AddAndStart("X", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiting := q.AddToQueue("A", 1, TierResident)
+	if err := q.SetMetadata(waiting.ID, map[string]string{"room": "12"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.StartTimerDelayed(waiting.ID, 30, 5); err != nil {
+		t.Fatal(err)
+	}
+	q.CancelDelayedStart(waiting.ID)
+	q.PauseAll()
+	q.ResumeAll()
+	q.CompleteNow(running.ID)
+	if err := q.StartTimer(waiting.ID, 30); err != nil {
+		t.Fatal(err)
+	}
+	q.Remove(running.ID)
+
+	want := []string{
+		"item_added X", "timer_started X", "item_added A", "item_updated A", "timer_started A", "item_updated A",
+		"item_updated X", "item_updated X", "item_completed X", "next_up A", "timer_started A", "item_removed X",
+	}
+	got := make([]string, 0, len(want))
+	for len(got) < len(want) {
+		select {
+		case event := <-events:
+			got = append(got, string(event.Type)+" "+event.Item.Name)
+		case <-time.After(time.Second):
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestEventBusDeliversToEverySubscriber(t *testing.T) {
+	bus := NewEventBus()
+	received := make(chan string, 8)
+	for _, name := range []string{"one", "two", "three"} {
+		name := name
+		unsubscribe := bus.Subscribe(func(event Event) { received <- name }, EventItemAdded)
+		defer unsubscribe()
+	}
+	unsubscribe := bus.Subscribe(func(event Event) { received <- "other" }, EventItemRemoved)
+	defer unsubscribe()
+
+	bus.Publish(Event{Type: EventItemAdded, At: time.Now()})
+
+	got := make([]string, 0, 3)
+	for len(got) < 3 {
+		select {
+		case name := <-received:
+			got = append(got, name)
+		case <-time.After(time.Second):
+			t.Fatalf("delivered to %v, want all three subscribers", got)
+		}
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != "one,three,two" {
+		t.Errorf("delivered to %v, want one, two and three", got)
+	}
+	select {
+	case name := <-received:
+		t.Errorf("event also delivered to %q", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
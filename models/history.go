@@ -0,0 +1,48 @@
+package models
+
+import "sync"
+
+// History keeps snapshots of the most recently completed loads in a
+// fixed-size ring, so the oldest is dropped once it is full
+type History struct {
+	mu    sync.Mutex
+	items []QueueItem
+	// next is where the following snapshot is written
+	next int
+	full bool
+}
+
+// NewHistory creates a history that keeps the last size completed loads
+func NewHistory(size int) *History {
+	return &History{items: make([]QueueItem, size)}
+}
+
+// record stores a snapshot of a completed load, overwriting the oldest once full
+func (h *History) record(item QueueItem) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.items[h.next] = item.snapshot()
+	h.next = (h.next + 1) % len(h.items)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Items returns copies of the recorded loads, newest first
+func (h *History) Items() []*QueueItem {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	count := h.next
+	if h.full {
+		count = len(h.items)
+	}
+
+	result := make([]*QueueItem, 0, count)
+	for i := 1; i <= count; i++ {
+		item := h.items[(h.next-i+len(h.items))%len(h.items)].snapshot()
+		result = append(result, &item)
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// IDGenerator produces IDs for new queue items
+type IDGenerator interface {
+	Next(item *QueueItem) string
+}
+
+// RandomIDGenerator generates random hex IDs. It is the default generator.
+type RandomIDGenerator struct{}
+
+// Next returns a random 16 character hex ID
+func (RandomIDGenerator) Next(item *QueueItem) string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// SequentialIDGenerator generates predictable IDs ("1", "2", ...) for
+// deterministic tests and demos
+type SequentialIDGenerator struct {
+	n atomic.Int64
+}
+
+// Next returns the next number in the sequence
+func (g *SequentialIDGenerator) Next(item *QueueItem) string {
+	return strconv.FormatInt(g.n.Add(1), 10)
+}
@@ -0,0 +1,52 @@
+package models
+
+import "testing"
+
+func TestSequentialIDGeneratorGivesPredictableIDs(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{IDGenerator: &SequentialIDGenerator{}})
+	defer q.Close()
+
+	for _, want := range []string{"1", "2", "3"} {
+		if got := q.AddToQueue("Ann", 1, TierResident).ID; got != want {
+			t.Errorf("ID %q, want %q", got, want)
+		}
+	}
+}
+
+// constantIDGenerator proposes the same ID every time, forcing collisions
+type constantIDGenerator struct{}
+
+func (constantIDGenerator) Next(*QueueItem) string { return "same" }
+
+func TestSameNameItemsGetDistinctIDs(t *testing.T) {
+	for _, gen := range []IDGenerator{RandomIDGenerator{}, constantIDGenerator{}} {
+		q := NewLaundryQueueWithOptions(Options{Machines: 2, IDGenerator: gen})
+
+		running, err := q.AddAndStart("Alex", 30, 1, TierResident)
+		if err != nil {
+			q.Close()
+			t.Fatal(err)
+		}
+		ids := map[string]bool{running.ID: true}
+		var added []*QueueItem
+		for i := 0; i < 50; i++ {
+			item := q.AddToQueue("Alex", 1, TierResident)
+			if ids[item.ID] {
+				t.Errorf("%T: ID %q given twice", gen, item.ID)
+			}
+			ids[item.ID] = true
+			added = append(added, item)
+		}
+
+		if !q.Remove(added[0].ID) {
+			t.Fatalf("%T: could not remove %q", gen, added[0].ID)
+		}
+		if find(q, added[1].ID) == nil || find(q, running.ID) == nil {
+			t.Errorf("%T: removing one Alex took another with it", gen)
+		}
+		if n := len(q.GetAll()); n != 50 {
+			t.Errorf("%T: %d items left, want 50", gen, n)
+		}
+		q.Close()
+	}
+}
@@ -1,7 +1,13 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,139 +18,1711 @@ const (
 	StatusInProgress = "in_progress"
 	// StatusCompleted indicates a queue item has finished
 	StatusCompleted = "completed"
+	// StatusTransit indicates a wash has finished and is waiting to be moved to the dryer
+	StatusTransit = "in_transit"
 
 	// AutoRemoveDelay is how long completed items stay before auto-removal
 	AutoRemoveDelay = 5 * time.Minute
-	// BackgroundWorkerInterval is how often the background worker runs
+	// BackgroundWorkerInterval is how often the background worker sweeps for
+	// auto-removal and timeouts; timers complete loads on their own
 	BackgroundWorkerInterval = 30 * time.Second
+
+	// DefaultLoadMinutes is the assumed length of a load that has no timer yet
+	DefaultLoadMinutes = 45
+	// MaxNumLoads is the most loads one person may queue at once
+	MaxNumLoads = 10
+	// PreStartNotice is how long before a delayed start EventStartingSoon is published
+	PreStartNotice = 2 * time.Minute
+	// DuplicateStartWindow is how long a repeat of the same start request is
+	// treated as a double-click rather than an error
+	DuplicateStartWindow = 5 * time.Second
+	// MaxMetadataEntries is the most metadata fields an item may carry
+	MaxMetadataEntries = 10
+	// MaxMetadataKeyLength and MaxMetadataValueLength bound each metadata field in bytes
+	MaxMetadataKeyLength   = 32
+	MaxMetadataValueLength = 256
+	// MaxIDAttempts is how many IDs are drawn from the generator before a
+	// taken one is made unique with a numeric suffix
+	MaxIDAttempts = 5
+	// MaxDurationMinutes bounds any duration or delay given in minutes, so
+	// converting it to a time.Duration can never overflow
+	MaxDurationMinutes = 7 * 24 * 60
+
+	// TierStaff is the priority tier for building staff
+	TierStaff = "staff"
+	// TierResident is the default priority tier
+	TierResident = "resident"
+	// TierGuest is the priority tier for visitors
+	TierGuest = "guest"
+)
+
+// IsValidStatus reports whether status is a known item status
+func IsValidStatus(status string) bool {
+	switch status {
+	case StatusWaiting, StatusInProgress, StatusTransit, StatusCompleted:
+		return true
+	}
+	return false
+}
+
+// FilterByStatus returns the items whose status is one of statuses, preserving order
+func FilterByStatus(items []*QueueItem, statuses ...string) []*QueueItem {
+	result := make([]*QueueItem, 0)
+	for _, item := range items {
+		for _, status := range statuses {
+			if item.Status == status {
+				result = append(result, item)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// FindByIDs returns the items with the given IDs in the order asked for, and
+// the IDs that matched no item. Repeated IDs are looked up once.
+func FindByIDs(items []*QueueItem, ids []string) ([]*QueueItem, []string) {
+	byID := make(map[string]*QueueItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	found := make([]*QueueItem, 0, len(ids))
+	missing := make([]string, 0)
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if item, ok := byID[id]; ok {
+			found = append(found, item)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return found, missing
+}
+
+// Urgency levels describe how close a load is to finishing
+const (
+	UrgencyRunning   = "running"
+	UrgencySoon      = "soon"
+	UrgencyFinishing = "finishing"
+	UrgencyComplete  = "complete"
+)
+
+// DefaultUrgencySoonMinutes and DefaultUrgencyFinishingMinutes are the
+// remaining-time thresholds at or below which a running load is "soon" or
+// "finishing" when Options leaves them unset
+const (
+	DefaultUrgencySoonMinutes      = 10
+	DefaultUrgencyFinishingMinutes = 2
 )
 
-// QueueItem represents a person in the laundry queue
-type QueueItem struct {
-	ID          string     `json:"id"`
-	Name        string     `json:"name"`
-	Status      string     `json:"status"`
-	StartTime   *time.Time `json:"start_time,omitempty"`
-	Duration    int        `json:"duration,omitempty"`
-	NumLoads    int        `json:"num_loads"`
-	CompletedAt *time.Time `json:"completed_at,omitempty"`
-	QueuedAt    time.Time  `json:"queued_at"`
+var (
+	// ErrNotFound is returned when no item has the given ID
+	ErrNotFound = errors.New("item not found")
+	// ErrNotWaiting is returned when an item is not waiting to start
+	ErrNotWaiting = errors.New("item is not waiting")
+	// ErrAlreadyCompleted is returned when an item's load has already finished
+	ErrAlreadyCompleted = errors.New("load already completed")
+	// ErrInvalidMetadata is returned when metadata exceeds the size limits
+	ErrInvalidMetadata = errors.New("invalid metadata")
+	// ErrStartTooSoon is returned when a start falls inside the stagger gap
+	ErrStartTooSoon = errors.New("start is too close to the previous one")
+	// ErrNoFreeMachine is returned when every machine is running or reserved
+	ErrNoFreeMachine = errors.New("no machine is free")
+	// ErrNotAllowed is returned when someone on a load is not on the allowlist
+	ErrNotAllowed = errors.New("not on the resident list")
+	// ErrOthersWaiting is returned when someone not in line tries to start
+	// ahead of people who are
+	ErrOthersWaiting = errors.New("others are already waiting")
+)
+
+// StartError is returned by the start paths when one of CanStart's rules
+// refuses a load. Reason is the message CanStart reports, and the rule's
+// sentinel error, such as ErrNoFreeMachine, is reachable with errors.Is.
+type StartError struct {
+	Err    error
+	Reason string
+}
+
+func (e *StartError) Error() string { return e.Reason }
+
+func (e *StartError) Unwrap() error { return e.Err }
+
+// IsAllowed reports whether name is on allowlist. Names are matched
+// case-insensitively, and everyone is allowed when the allowlist is empty.
+func IsAllowed(allowlist []string, name string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+	name = strings.TrimSpace(name)
+	for _, allowed := range allowlist {
+		if strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultTierWeights returns the tier weights a queue uses unless its options
+// set others: staff first, then residents, then guests
+func DefaultTierWeights() map[string]int {
+	return map[string]int{
+		TierStaff:    0,
+		TierResident: 1,
+		TierGuest:    2,
+	}
+}
+
+// IsValidTier reports whether tier is a known priority tier
+func IsValidTier(tier string) bool {
+	switch tier {
+	case TierStaff, TierResident, TierGuest:
+		return true
+	}
+	return false
+}
+
+// tierWeight returns the ordering weight for a tier, treating unknown tiers
+// and tiers missing from weights as residents
+func tierWeight(weights map[string]int, tier string) int {
+	if weight, ok := weights[tier]; ok {
+		return weight
+	}
+	return weights[TierResident]
+}
+
+// QueueItem represents a person in the laundry queue
+type QueueItem struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Owners      []string   `json:"owners,omitempty"`
+	Status      string     `json:"status"`
+	StartTime   *time.Time `json:"start_time,omitempty"`
+	Duration    int        `json:"duration,omitempty"`
+	NumLoads    int        `json:"num_loads"`
+	Tier        string     `json:"tier"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	TransitAt   *time.Time `json:"transit_at,omitempty"`
+	Drying      bool       `json:"drying,omitempty"`
+	QueuedAt    time.Time  `json:"queued_at"`
+	// PossiblyAbsent marks a front-of-line item that left a free machine unused
+	PossiblyAbsent bool `json:"possibly_absent,omitempty"`
+	// AssistedBy names the staff member doing this load on the owner's
+	// behalf as a laundry service, or is empty
+	AssistedBy string `json:"assisted_by,omitempty"`
+	// PausedAt is when PauseAll froze the load's countdown, or the transit
+	// timeout of a wash waiting to be moved to the dryer
+	PausedAt *time.Time `json:"paused_at,omitempty"`
+	// Metadata holds deployment-specific fields such as a room number
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// MachineID is the 1-based washer the load was started on, or 0 before
+	// it starts. A finished wash keeps it so its owner knows where to go.
+	MachineID int `json:"machine_id,omitempty"`
+
+	// preStartNotified records that EventStartingSoon was sent for a delayed start
+	preStartNotified bool
+	// startedAt is when the timer was last started, for spotting duplicate requests
+	startedAt time.Time
+	// nextUpNotified records that EventNextUp was sent for the item
+	nextUpNotified bool
+	// timer completes the load the moment its countdown runs out
+	timer *time.Timer
+}
+
+// snapshot returns a copy of the item that shares no owners or metadata with
+// it, so later changes to the live item do not show through
+func (q QueueItem) snapshot() QueueItem {
+	if q.Owners != nil {
+		q.Owners = append([]string(nil), q.Owners...)
+	}
+	if q.Metadata != nil {
+		metadata := make(map[string]string, len(q.Metadata))
+		for key, value := range q.Metadata {
+			metadata[key] = value
+		}
+		q.Metadata = metadata
+	}
+	q.timer = nil
+	return q
+}
+
+// ParseOwners splits a comma-separated list of names, trimming blanks and
+// dropping case-insensitive duplicates
+func ParseOwners(names string) []string {
+	owners := make([]string, 0)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		duplicate := false
+		for _, owner := range owners {
+			if strings.EqualFold(owner, name) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			owners = append(owners, name)
+		}
+	}
+	return owners
+}
+
+// setOwners sets the item's display name and, for shared loads, its owners
+func (q *QueueItem) setOwners(names string) {
+	owners := ParseOwners(names)
+	q.Name = strings.Join(owners, ", ")
+	q.Owners = nil
+	if len(owners) > 1 {
+		q.Owners = owners
+	}
+}
+
+// HasOwner reports whether name (case-insensitive) is one of the load's owners
+func (q *QueueItem) HasOwner(name string) bool {
+	if len(q.Owners) == 0 {
+		return strings.EqualFold(q.Name, name)
+	}
+	for _, owner := range q.Owners {
+		if strings.EqualFold(owner, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// minutes converts a count of minutes to a time.Duration, clamping it to
+// between zero and MaxDurationMinutes
+func minutes(n int) time.Duration {
+	if n < 0 {
+		n = 0
+	} else if n > MaxDurationMinutes {
+		n = MaxDurationMinutes
+	}
+	return time.Duration(n) * time.Minute
+}
+
+// clock returns the moment the load's countdown is measured at: when it was
+// paused, or now
+func (q *QueueItem) clock() time.Time {
+	if q.PausedAt != nil {
+		return *q.PausedAt
+	}
+	return time.Now()
+}
+
+// EndTime returns when the load's timer runs out, or the zero time if it has
+// not started. For a paused load it is when the timer would run out if
+// resumed now.
+func (q *QueueItem) EndTime() time.Time {
+	if q.StartTime == nil {
+		return time.Time{}
+	}
+	return time.Now().Add(q.StartTime.Add(minutes(q.Duration)).Sub(q.clock()))
+}
+
+// GetRemainingMinutes returns how many minutes are left, never more than the
+// load's duration. A load whose start time hasn't arrived yet, whether from a
+// delayed start or clock skew, still has its full duration left.
+func (q *QueueItem) GetRemainingMinutes() int {
+	if q.Status != StatusInProgress || q.StartTime == nil || q.Duration <= 0 {
+		return 0
+	}
+	full := int(minutes(q.Duration) / time.Minute)
+	if q.IsPending() {
+		return full
+	}
+	remaining := int(q.StartTime.Add(minutes(q.Duration)).Sub(q.clock()).Minutes())
+	if remaining < 0 {
+		return 0
+	}
+	if remaining > full {
+		return full
+	}
+	return remaining
+}
+
+// IsPending reports whether the load has a delayed start that hasn't arrived yet
+func (q *QueueItem) IsPending() bool {
+	return q.Status == StatusInProgress && q.StartTime != nil && q.StartTime.After(q.clock())
+}
+
+// StartsInMinutes returns how many minutes until a delayed start begins, rounded up
+func (q *QueueItem) StartsInMinutes() int {
+	if !q.IsPending() {
+		return 0
+	}
+	return int(math.Ceil(q.StartTime.Sub(q.clock()).Minutes()))
+}
+
+// minutesUntilFree returns how long until the load releases the machine,
+// including any delay before it starts
+func (q *QueueItem) minutesUntilFree() int {
+	return q.StartsInMinutes() + q.GetRemainingMinutes()
+}
+
+// IsTimerExpired checks if the timer has expired, to the second. A load that
+// hasn't started yet can't have expired, even with no duration.
+func (q *QueueItem) IsTimerExpired() bool {
+	if q.Status != StatusInProgress || q.StartTime == nil {
+		return true
+	}
+	return !q.IsPending() && !q.clock().Before(q.StartTime.Add(minutes(q.Duration)))
+}
+
+// Urgency returns how close the load is to finishing, or "" for waiting items.
+// A running load is "soon" with soonMinutes or fewer left and "finishing" with
+// finishingMinutes or fewer.
+func (q *QueueItem) Urgency(soonMinutes, finishingMinutes int) string {
+	switch q.Status {
+	case StatusWaiting:
+		return ""
+	case StatusInProgress:
+	default:
+		return UrgencyComplete
+	}
+
+	remaining := q.GetRemainingMinutes()
+	switch {
+	case remaining <= 0:
+		return UrgencyComplete
+	case remaining <= finishingMinutes:
+		return UrgencyFinishing
+	case remaining <= soonMinutes:
+		return UrgencySoon
+	default:
+		return UrgencyRunning
+	}
+}
+
+// ShouldAutoRemove checks if completed item should be removed
+func (q *QueueItem) ShouldAutoRemove() bool {
+	if q.Status != StatusCompleted || q.CompletedAt == nil {
+		return false
+	}
+	return time.Since(*q.CompletedAt) > AutoRemoveDelay
+}
+
+// autoRemoveIn returns how long until a completed item is auto-removed, or a
+// negative duration once it is due
+func (q *QueueItem) autoRemoveIn() time.Duration {
+	return q.CompletedAt.Add(AutoRemoveDelay).Sub(time.Now())
+}
+
+// AutoRemoveInMinutes returns how many minutes, rounded up, until a completed
+// item is auto-removed, or 0 if it isn't completed or is already due
+func (q *QueueItem) AutoRemoveInMinutes() int {
+	if q.Status != StatusCompleted || q.CompletedAt == nil {
+		return 0
+	}
+	if left := q.autoRemoveIn(); left > 0 {
+		return int(math.Ceil(left.Minutes()))
+	}
+	return 0
+}
+
+// waitingItems returns the waiting items in the order they will be served:
+// by tier weight, then first come first served within a tier
+func waitingItems(items []*QueueItem, weights map[string]int) []*QueueItem {
+	waiting := make([]*QueueItem, 0)
+	for _, item := range items {
+		if item.Status == StatusWaiting {
+			waiting = append(waiting, item)
+		}
+	}
+	sort.SliceStable(waiting, func(i, j int) bool {
+		return tierWeight(weights, waiting[i].Tier) < tierWeight(weights, waiting[j].Tier)
+	})
+	return waiting
+}
+
+// WaitingPositions maps each waiting item's ID to its 1-based queue position,
+// ordering tiers by weights
+func WaitingPositions(items []*QueueItem, weights map[string]int) map[string]int {
+	positions := make(map[string]int)
+	for i, item := range waitingItems(items, weights) {
+		positions[item.ID] = i + 1
+	}
+	return positions
+}
+
+// Position is a waiting item's place in line
+type Position struct {
+	ID       string `json:"id"`
+	Position int    `json:"position"`
+}
+
+// SortedPositions returns the waiting positions as a slice ordered by position,
+// giving API clients a stable order that a map cannot
+func SortedPositions(items []*QueueItem, weights map[string]int) []Position {
+	waiting := waitingItems(items, weights)
+	positions := make([]Position, 0, len(waiting))
+	for i, item := range waiting {
+		positions = append(positions, Position{ID: item.ID, Position: i + 1})
+	}
+	return positions
+}
+
+// holdsMachine reports whether a load is running on or has reserved a washer.
+// A load whose start time is still ahead hasn't started, but it holds the
+// machine for its owner, so it counts as a reservation. Dryer loads don't hold a washer.
+func (q *QueueItem) holdsMachine() bool {
+	return q.Status == StatusInProgress && !q.Drying && !q.IsTimerExpired()
+}
+
+// freeMachines returns the IDs of the machines, numbered 1 to machines, that
+// no load holds. Loads without a machine ID, such as ones saved before
+// machines were tracked, each take up one of the free machines.
+func freeMachines(items []*QueueItem, machines int) []int {
+	held := make(map[int]bool)
+	unassigned := 0
+	for _, item := range items {
+		if !item.holdsMachine() {
+			continue
+		}
+		if item.MachineID > 0 {
+			held[item.MachineID] = true
+		} else {
+			unassigned++
+		}
+	}
+
+	free := make([]int, 0, machines)
+	for id := 1; id <= machines; id++ {
+		if !held[id] {
+			free = append(free, id)
+		}
+	}
+	if unassigned >= len(free) {
+		return free[:0]
+	}
+	return free[unassigned:]
+}
+
+// EstimateStarts estimates when each waiting item's turn comes up, given the
+// tier weights, how many machines share the queue and how long a load with no
+// timer is assumed to take. Each waiting item, in order, takes the machine that frees up first,
+// for its timer's length, or loadMinutes, for each of its loads.
+func EstimateStarts(items []*QueueItem, weights map[string]int, machines, loadMinutes int, now time.Time) map[string]time.Time {
+	freeAt := make([]time.Time, 0, machines)
+	for _, item := range items {
+		if !item.holdsMachine() {
+			continue
+		}
+		end := item.EndTime()
+		if end.Before(now) {
+			end = now
+		}
+		freeAt = append(freeAt, end)
+	}
+	for len(freeAt) < machines {
+		freeAt = append(freeAt, now)
+	}
+
+	starts := make(map[string]time.Time)
+	for _, item := range waitingItems(items, weights) {
+		next := 0
+		for i := range freeAt {
+			if freeAt[i].Before(freeAt[next]) {
+				next = i
+			}
+		}
+		starts[item.ID] = freeAt[next]
+		freeAt[next] = freeAt[next].Add(minutes(item.expectedMinutes(loadMinutes)))
+	}
+	return starts
+}
+
+// expectedMinutes returns how long all of an item's loads are expected to
+// take: its timer's length, or loadMinutes if it has none, for each load
+func (q *QueueItem) expectedMinutes(loadMinutes int) int {
+	perLoad := q.Duration
+	if perLoad <= 0 {
+		perLoad = loadMinutes
+	}
+	return q.NumLoads * perLoad
+}
+
+// ceilMinutes returns d in whole minutes, rounded up, or 0 if d isn't positive
+func ceilMinutes(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Minutes()))
+}
+
+// ETAMinutes estimates the minutes until a waiting item's turn comes up, using
+// EstimateStarts, or until an in-progress item's load finishes
+func ETAMinutes(items []*QueueItem, target *QueueItem, weights map[string]int, machines, loadMinutes int, now time.Time) int {
+	switch target.Status {
+	case StatusInProgress:
+		return target.minutesUntilFree()
+	case StatusWaiting:
+	default:
+		return 0
+	}
+
+	if position, ok := WaitingPositions(items, weights)[target.ID]; ok {
+		return WaitForPosition(items, position, weights, machines, loadMinutes, now)
+	}
+	return 0
+}
+
+// WaitForPosition estimates the minutes until the given 1-based waiting
+// position is served across every machine, using EstimateStarts. Slots past
+// the end of the line are assumed to hold one load of loadMinutes each.
+func WaitForPosition(items []*QueueItem, position int, weights map[string]int, machines, loadMinutes int, now time.Time) int {
+	if position < 1 {
+		return 0
+	}
+
+	waiting := waitingItems(items, weights)
+	if position <= len(waiting) {
+		return ceilMinutes(EstimateStarts(items, weights, machines, loadMinutes, now)[waiting[position-1].ID].Sub(now))
+	}
+
+	// Pad the line with anonymous guest loads, which sort after everyone
+	// already waiting. They share the empty ID, so the estimate left under
+	// it is the last one's.
+	padded := append(make([]*QueueItem, 0, len(items)+position-len(waiting)), items...)
+	for i := len(waiting); i < position; i++ {
+		padded = append(padded, &QueueItem{Status: StatusWaiting, NumLoads: 1, Tier: TierGuest})
+	}
+	return ceilMinutes(EstimateStarts(padded, weights, machines, loadMinutes, now)[""].Sub(now))
+}
+
+// Options configures optional queue behaviour
+type Options struct {
+	// TransitTimeout is how long a finished wash may wait to be moved to the
+	// dryer before it is marked completed. Zero disables the transit state.
+	TransitTimeout time.Duration
+	// MaxLoadDuration is the longest any load may hold the machine, whatever
+	// its declared duration. Zero means no cap.
+	MaxLoadDuration time.Duration
+	// DisableAutoRemove keeps completed items until they are removed by hand
+	DisableAutoRemove bool
+	// IDGenerator assigns IDs to new items. Defaults to RandomIDGenerator.
+	IDGenerator IDGenerator
+	// IdleAlertAfter is how long the machine may sit free while people are
+	// waiting before EventMachineIdle is published. Zero disables the alert.
+	IdleAlertAfter time.Duration
+	// AbsentAfter is how long the machine may sit free before the front of
+	// the line is flagged as possibly absent. Zero disables the flag.
+	AbsentAfter time.Duration
+	// AutoSkipAbsent moves a possibly absent item behind the next person waiting
+	AutoSkipAbsent bool
+	// RequeuePriority puts a load requeued on the same day it finished at the
+	// front of its tier instead of the back of the line
+	RequeuePriority bool
+	// TimelineRetention is how far back StateAt can reconstruct the queue.
+	// Zero disables the timeline.
+	TimelineRetention time.Duration
+	// HistorySize is how many completed loads GetHistory remembers. Zero
+	// disables the history.
+	HistorySize int
+	// StatePath is a JSON file the queue is loaded from at startup and saved
+	// to after every change. Empty keeps the queue in memory only.
+	StatePath string
+	// StartGap is how long after the most recent start another load should
+	// start, so completions are staggered. Zero disables staggering.
+	StartGap time.Duration
+	// RejectBunchedStarts makes StartTimerDelayed refuse starts inside the
+	// gap instead of only suggesting a delay
+	RejectBunchedStarts bool
+	// Machines is how many washers share the queue. Values below 1 mean one.
+	Machines int
+	// LoadMinutes is how long a load without a timer is assumed to take
+	// when estimating start times. Zero means DefaultLoadMinutes.
+	LoadMinutes int
+	// Allowlist restricts who may start a load; empty allows everyone
+	Allowlist []string
+	// UrgencySoonMinutes and UrgencyFinishingMinutes are the remaining
+	// minutes at or below which a running load is "soon" or "finishing".
+	// Zero means DefaultUrgencySoonMinutes or DefaultUrgencyFinishingMinutes.
+	UrgencySoonMinutes      int
+	UrgencyFinishingMinutes int
+	// TierWeights orders waiting items by tier; lower weights are served
+	// first. Nil means DefaultTierWeights. The queue keeps its own copy.
+	TierWeights map[string]int
+}
+
+// LaundryQueue manages the queue
+type LaundryQueue struct {
+	mu       sync.RWMutex
+	items    []*QueueItem
+	opts     Options
+	idGen    IDGenerator
+	events   *EventBus
+	timeline *Timeline
+	history  *History
+
+	// idleSince is when the machine was first seen free with people waiting
+	idleSince    time.Time
+	idleNotified bool
+	// lastModified is when the queue's items last changed
+	lastModified time.Time
+	// totalAdds counts every item added since startup; it never goes down
+	totalAdds atomic.Int64
+	// paused freezes every countdown in the room until ResumeAll
+	paused bool
+	// dirty records a change made under the lock that unlock must save
+	dirty bool
+	// saveSeq numbers the snapshots taken under the lock; savedSeq, guarded
+	// by saveMu, is the newest one written to disk
+	saveSeq  uint64
+	saveMu   sync.Mutex
+	savedSeq uint64
+	// stop is closed by Close to end the background worker, which closes
+	// workerDone once it has returned
+	stop       chan struct{}
+	workerDone chan struct{}
+	closeOnce  sync.Once
+
+	// peakWaiting is the most people ever waiting at once; dayPeakWaiting is
+	// the most waiting at once on peakDay (formatted YYYY-MM-DD)
+	peakWaiting    int
+	dayPeakWaiting int
+	peakDay        string
+}
+
+// NewLaundryQueue creates a new queue with default options
+func NewLaundryQueue() *LaundryQueue {
+	return NewLaundryQueueWithOptions(Options{})
+}
+
+// NewLaundryQueueWithMachines creates a queue shared by n washers
+func NewLaundryQueueWithMachines(n int) *LaundryQueue {
+	return NewLaundryQueueWithOptions(Options{Machines: n})
+}
+
+// NewLaundryQueueWithOptions creates a new queue with the given options
+func NewLaundryQueueWithOptions(opts Options) *LaundryQueue {
+	queue := &LaundryQueue{
+		items:  make([]*QueueItem, 0),
+		opts:   opts,
+		idGen:  opts.IDGenerator,
+		events: NewEventBus(),
+
+		stop:       make(chan struct{}),
+		workerDone: make(chan struct{}),
+	}
+	if queue.idGen == nil {
+		queue.idGen = RandomIDGenerator{}
+	}
+	// The weights are copied so a caller changing its map can't reorder the line
+	queue.opts.TierWeights = DefaultTierWeights()
+	if opts.TierWeights != nil {
+		queue.opts.TierWeights = make(map[string]int, len(opts.TierWeights))
+		for tier, weight := range opts.TierWeights {
+			queue.opts.TierWeights[tier] = weight
+		}
+	}
+	if opts.StatePath != "" {
+		state := loadState(opts.StatePath)
+		queue.items = state.Items
+		queue.paused = state.Paused
+		queue.peakWaiting = state.PeakWaiting
+		queue.dayPeakWaiting = state.DayPeakWaiting
+		queue.peakDay = state.PeakDay
+		queue.lastModified = time.Now()
+	}
+	if opts.TimelineRetention > 0 {
+		queue.timeline = NewTimeline(opts.TimelineRetention)
+		queue.timeline.seed(queue.items)
+	}
+	if opts.HistorySize > 0 {
+		queue.history = NewHistory(opts.HistorySize)
+	}
+
+	// Restored loads are scheduled only once the queue is fully set up, as
+	// one that expired while the server was down completes straight away
+	queue.mu.Lock()
+	for _, item := range queue.items {
+		queue.scheduleCompletion(item)
+	}
+	queue.mu.Unlock()
+
+	go queue.backgroundWorker()
+	return queue
+}
+
+// Close stops the background worker, waiting for it to return, and cancels
+// every load's completion timer. The queue's state is already saved after each
+// change, so nothing is lost. Close may be called more than once.
+func (q *LaundryQueue) Close() {
+	q.closeOnce.Do(func() {
+		close(q.stop)
+		<-q.workerDone
+
+		q.mu.Lock()
+		defer q.unlock()
+		for _, item := range q.items {
+			q.stopTimer(item)
+		}
+	})
+}
+
+// Events returns the bus that queue state changes are published on
+func (q *LaundryQueue) Events() *EventBus {
+	return q.events
+}
+
+// publish records a change to item and sends a snapshot of it on the event
+// bus. Callers must hold the lock.
+func (q *LaundryQueue) publish(eventType EventType, item *QueueItem) {
+	now := time.Now()
+	q.touch(now)
+	q.dispatch(Event{Type: eventType, Item: item.snapshot(), At: now})
+}
+
+// touch records that the queue changed at now. Callers must hold the lock.
+func (q *LaundryQueue) touch(now time.Time) {
+	q.lastModified = now
+	q.dirty = true
+}
+
+// unlock releases the write lock. If the queue changed while locked, it takes
+// a snapshot first, so the save sees a consistent queue, and writes it once
+// the lock is released, so readers never wait on the disk.
+func (q *LaundryQueue) unlock() {
+	if !q.dirty || q.opts.StatePath == "" {
+		q.dirty = false
+		q.mu.Unlock()
+		return
+	}
+	q.dirty = false
+	state, seq := q.stateSnapshot()
+	q.mu.Unlock()
+	q.save(state, seq)
+}
+
+// LastModified returns when the queue's items last changed, or the zero time
+// if they never have
+func (q *LaundryQueue) LastModified() time.Time {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.lastModified
+}
+
+// dispatch sends event on the event bus and records it on the timeline, if
+// there is one. Completions are also kept in the history.
+func (q *LaundryQueue) dispatch(event Event) {
+	q.events.Publish(event)
+	if q.timeline != nil {
+		q.timeline.record(event)
+	}
+	if q.history != nil && event.Type == EventItemCompleted {
+		q.history.record(event.Item)
+	}
+}
+
+// clampDuration limits a requested duration in minutes to the configured
+// maximum, or to MaxDurationMinutes, and treats negative durations as zero
+func (q *LaundryQueue) clampDuration(duration int) int {
+	if max := q.MaxLoadMinutes(); max > 0 && duration > max {
+		return max
+	}
+	return int(minutes(duration) / time.Minute)
+}
+
+// exceedsMaxLoad reports whether a running load has held the machine past the absolute cap
+func (q *LaundryQueue) exceedsMaxLoad(item *QueueItem, now time.Time) bool {
+	return q.opts.MaxLoadDuration > 0 && item.StartTime != nil && item.PausedAt == nil && now.Sub(*item.StartTime) >= q.opts.MaxLoadDuration
+}
+
+// AutoRemoveEnabled reports whether completed items are removed automatically
+func (q *LaundryQueue) AutoRemoveEnabled() bool {
+	return !q.opts.DisableAutoRemove
+}
+
+// Machines returns how many washers share the queue
+func (q *LaundryQueue) Machines() int {
+	if q.opts.Machines < 1 {
+		return 1
+	}
+	return q.opts.Machines
+}
+
+// LoadMinutes returns how long a load without a timer is assumed to take
+func (q *LaundryQueue) LoadMinutes() int {
+	if q.opts.LoadMinutes <= 0 {
+		return DefaultLoadMinutes
+	}
+	return q.opts.LoadMinutes
+}
+
+// UrgencyMinutes returns the remaining-time thresholds for a "soon" and a
+// "finishing" load, to pass to QueueItem.Urgency
+func (q *LaundryQueue) UrgencyMinutes() (soon, finishing int) {
+	soon, finishing = q.opts.UrgencySoonMinutes, q.opts.UrgencyFinishingMinutes
+	if soon <= 0 {
+		soon = DefaultUrgencySoonMinutes
+	}
+	if finishing <= 0 {
+		finishing = DefaultUrgencyFinishingMinutes
+	}
+	return soon, finishing
+}
+
+// TierWeights returns a copy of the weights the queue orders tiers by, to
+// pass to WaitingPositions and the other ordering helpers
+func (q *LaundryQueue) TierWeights() map[string]int {
+	weights := make(map[string]int, len(q.opts.TierWeights))
+	for tier, weight := range q.opts.TierWeights {
+		weights[tier] = weight
+	}
+	return weights
+}
+
+// EstimatedStartTime estimates when a waiting item's turn will come up,
+// accounting for every machine, or returns nil if the item isn't waiting
+func (q *LaundryQueue) EstimatedStartTime(id string) *time.Time {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	start, ok := EstimateStarts(q.items, q.opts.TierWeights, q.Machines(), q.LoadMinutes(), time.Now())[id]
+	if !ok {
+		return nil
+	}
+	return &start
+}
+
+// MaxLoadMinutes returns the configured load cap in minutes, or 0 if there is none
+func (q *LaundryQueue) MaxLoadMinutes() int {
+	return int(q.opts.MaxLoadDuration / time.Minute)
+}
+
+// loadFinished reports whether an in-progress load has run out its timer or the load cap
+func (q *LaundryQueue) loadFinished(item *QueueItem, now time.Time) bool {
+	return item.Status == StatusInProgress && (item.IsTimerExpired() || q.exceedsMaxLoad(item, now))
+}
+
+// scheduleCompletion arranges for an in-progress load to be completed the
+// moment its timer or the load cap runs out, replacing any earlier schedule.
+// Paused loads are rescheduled by ResumeAll. Callers must hold the lock.
+func (q *LaundryQueue) scheduleCompletion(item *QueueItem) {
+	q.stopTimer(item)
+	if item.Status != StatusInProgress || item.StartTime == nil || item.PausedAt != nil {
+		return
+	}
+
+	due := item.EndTime()
+	if q.opts.MaxLoadDuration > 0 {
+		if limit := item.StartTime.Add(q.opts.MaxLoadDuration); limit.Before(due) {
+			due = limit
+		}
+	}
+	item.timer = time.AfterFunc(time.Until(due), func() {
+		q.CompleteExpiredAndNotify()
+	})
+}
+
+// stopTimer cancels a load's scheduled completion. Callers must hold the lock.
+func (q *LaundryQueue) stopTimer(item *QueueItem) {
+	if item.timer != nil {
+		item.timer.Stop()
+		item.timer = nil
+	}
+}
+
+// finishLoad moves a finished load to transit or completed. Callers must hold the lock.
+func (q *LaundryQueue) finishLoad(item *QueueItem, now time.Time) {
+	if q.opts.TransitTimeout > 0 && !item.Drying {
+		item.Status = StatusTransit
+		item.TransitAt = &now
+		q.publish(EventItemInTransit, item)
+	} else {
+		item.Status = StatusCompleted
+		item.CompletedAt = &now
+		q.publish(EventItemCompleted, item)
+	}
+}
+
+// backgroundWorker periodically sends start reminders, times out loads left in
+// transit, auto-removes finished items and checks for an idle machine, until
+// the queue is closed
+func (q *LaundryQueue) backgroundWorker() {
+	defer close(q.workerDone)
+	ticker := time.NewTicker(BackgroundWorkerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+			q.sweep()
+		}
+	}
+}
+
+// sweep does one pass of the background worker's checks
+func (q *LaundryQueue) sweep() {
+	q.mu.Lock()
+	defer q.unlock()
+
+	now := time.Now()
+	newItems := make([]*QueueItem, 0)
+	for _, item := range q.items {
+		if item.IsPending() && item.PausedAt == nil && !item.preStartNotified && item.StartTime.Sub(now) <= PreStartNotice {
+			item.preStartNotified = true
+			q.publish(EventStartingSoon, item)
+		}
+
+		if item.Status == StatusTransit && item.TransitAt != nil && !q.paused && now.Sub(*item.TransitAt) > q.opts.TransitTimeout {
+			item.Status = StatusCompleted
+			item.CompletedAt = &now
+			q.publish(EventItemCompleted, item)
+		}
+
+		if q.opts.DisableAutoRemove || !item.ShouldAutoRemove() {
+			newItems = append(newItems, item)
+		} else {
+			q.publish(EventItemRemoved, item)
+		}
+	}
+	q.items = newItems
+	q.checkIdle(now)
+	q.nextUp(now)
+}
+
+// checkIdle tracks how long the machine has sat free while people wait and
+// publishes EventMachineIdle, for the front of the line, once per idle spell
+// that outlasts IdleAlertAfter. Callers must hold the lock.
+func (q *LaundryQueue) checkIdle(now time.Time) {
+	waiting := waitingItems(q.items, q.opts.TierWeights)
+	if len(waiting) == 0 || len(freeMachines(q.items, q.Machines())) == 0 {
+		q.idleSince = time.Time{}
+		q.idleNotified = false
+		for _, item := range q.items {
+			if item.PossiblyAbsent {
+				item.PossiblyAbsent = false
+				q.publish(EventItemUpdated, item)
+			}
+		}
+		return
+	}
+
+	if q.idleSince.IsZero() {
+		q.idleSince = now
+	}
+	if q.opts.IdleAlertAfter > 0 && !q.idleNotified && now.Sub(q.idleSince) >= q.opts.IdleAlertAfter {
+		q.idleNotified = true
+		q.publish(EventMachineIdle, waiting[0])
+	}
+	if q.opts.AbsentAfter > 0 && !waiting[0].PossiblyAbsent && now.Sub(q.idleSince) >= q.opts.AbsentAfter {
+		waiting[0].PossiblyAbsent = true
+		q.publish(EventPossiblyAbsent, waiting[0])
+		if q.opts.AutoSkipAbsent && len(waiting) > 1 {
+			q.skip(waiting[0], waiting[1])
+			q.idleSince = now
+			q.idleNotified = false
+		}
+	}
+}
+
+// nextUp publishes EventNextUp for each of the waiting items at the front of
+// the line that a free machine is ready for and that haven't been announced
+// yet, so each item is announced exactly once, and returns snapshots of the
+// items it announced. Callers must hold the lock.
+func (q *LaundryQueue) nextUp(now time.Time) []QueueItem {
+	waiting := waitingItems(q.items, q.opts.TierWeights)
+	if free := len(freeMachines(q.items, q.Machines())); len(waiting) > free {
+		waiting = waiting[:free]
+	}
+
+	announced := make([]QueueItem, 0)
+	for _, item := range waiting {
+		if item.nextUpNotified {
+			continue
+		}
+		item.nextUpNotified = true
+		q.dirty = true
+		event := Event{Type: EventNextUp, Item: item.snapshot(), At: now}
+		q.dispatch(event)
+		announced = append(announced, event.Item)
+	}
+	return announced
+}
+
+// skip moves item to just after next in the queue. Tier ordering still
+// applies, so a higher-tier item keeps its place. Callers must hold the lock.
+func (q *LaundryQueue) skip(item, next *QueueItem) {
+	reordered := make([]*QueueItem, 0, len(q.items))
+	for _, other := range q.items {
+		if other == item {
+			continue
+		}
+		reordered = append(reordered, other)
+		if other == next {
+			reordered = append(reordered, item)
+		}
+	}
+	q.items = reordered
+}
+
+// ItemDetails are optional fields set on an item as it is added, so they are
+// part of the item from its first event
+type ItemDetails struct {
+	// Metadata holds deployment-specific fields such as a room number
+	Metadata map[string]string
+	// AssistedBy names the staff member doing the load as a laundry service
+	AssistedBy string
+}
+
+// apply sets the details on a new item
+func (d ItemDetails) apply(item *QueueItem) {
+	if len(d.Metadata) > 0 {
+		item.Metadata = make(map[string]string, len(d.Metadata))
+		for key, value := range d.Metadata {
+			item.Metadata[key] = value
+		}
+	}
+	item.AssistedBy = strings.TrimSpace(d.AssistedBy)
+}
+
+// AddToQueue adds a new person to the queue. A comma-separated name adds a
+// load shared by several owners.
+func (q *LaundryQueue) AddToQueue(name string, numLoads int, tier string) *QueueItem {
+	item, _ := q.AddToQueueWithDetails(name, numLoads, tier, ItemDetails{})
+	return item
+}
+
+// AddToQueueWithDetails adds a new person to the queue like AddToQueue, with
+// details set before the item is published. It returns an ErrInvalidMetadata
+// error, adding nobody, if the metadata is over the limits.
+func (q *LaundryQueue) AddToQueueWithDetails(name string, numLoads int, tier string, details ItemDetails) (*QueueItem, error) {
+	if err := ValidateMetadata(details.Metadata); err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.unlock()
+
+	item := &QueueItem{
+		Status:   StatusWaiting,
+		NumLoads: numLoads,
+		Tier:     tier,
+		QueuedAt: time.Now(),
+	}
+	item.setOwners(name)
+	details.apply(item)
+	item.ID = q.nextID(item)
+	q.items = append(q.items, item)
+	q.recordPeak(item.QueuedAt)
+	q.totalAdds.Add(1)
+	q.publish(EventItemAdded, item)
+	return item, nil
+}
+
+// nextID returns an ID from the generator that no item in the queue has, so
+// a generator that repeats itself, such as a sequence restarted over a saved
+// queue, can't make one item's actions hit another. Callers must hold the lock.
+func (q *LaundryQueue) nextID(item *QueueItem) string {
+	id := q.idGen.Next(item)
+	for attempt := 1; attempt < MaxIDAttempts && q.hasID(id); attempt++ {
+		id = q.idGen.Next(item)
+	}
+	base := id
+	for n := 2; q.hasID(id); n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	return id
+}
+
+// hasID reports whether any item has the given ID. Callers must hold the lock.
+func (q *LaundryQueue) hasID(id string) bool {
+	for _, item := range q.items {
+		if item.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// recordPeak updates the all-time and daily peak waiting counts. Callers must hold the lock.
+func (q *LaundryQueue) recordPeak(now time.Time) {
+	waiting := len(waitingItems(q.items, q.opts.TierWeights))
+	if day := now.Format("2006-01-02"); day != q.peakDay {
+		q.peakDay = day
+		q.dayPeakWaiting = 0
+	}
+	if waiting > q.dayPeakWaiting {
+		q.dayPeakWaiting = waiting
+	}
+	if waiting > q.peakWaiting {
+		q.peakWaiting = waiting
+	}
+}
+
+// ValidateMetadata checks metadata against the count and size limits,
+// returning an error wrapping ErrInvalidMetadata that names the problem
+func ValidateMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxMetadataEntries {
+		return fmt.Errorf("%w: at most %d fields", ErrInvalidMetadata, MaxMetadataEntries)
+	}
+	for key, value := range metadata {
+		if key == "" || len(key) > MaxMetadataKeyLength {
+			return fmt.Errorf("%w: keys must be 1-%d bytes", ErrInvalidMetadata, MaxMetadataKeyLength)
+		}
+		if len(value) > MaxMetadataValueLength {
+			return fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidMetadata, key, MaxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// SetMetadata replaces an item's metadata with a copy of metadata. It returns
+// ErrNotFound or an ErrInvalidMetadata error.
+func (q *LaundryQueue) SetMetadata(id string, metadata map[string]string) error {
+	if err := ValidateMetadata(metadata); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.unlock()
+
+	for _, item := range q.items {
+		if item.ID == id {
+			item.Metadata = make(map[string]string, len(metadata))
+			for key, value := range metadata {
+				item.Metadata[key] = value
+			}
+			q.publish(EventItemUpdated, item)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// SetAssistedBy records that staff is doing an item's load as a laundry
+// service, or clears it when staff is empty. It returns ErrNotFound if there
+// is no such item.
+func (q *LaundryQueue) SetAssistedBy(id, staff string) error {
+	q.mu.Lock()
+	defer q.unlock()
+
+	for _, item := range q.items {
+		if item.ID == id {
+			item.AssistedBy = strings.TrimSpace(staff)
+			q.publish(EventItemUpdated, item)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// UpdateItem corrects the name and load count of a waiting item in place,
+// keeping its QueuedAt and so its place in line. A running load may be
+// renamed or have loads added, but not removed. It returns false if the item
+// isn't found, has finished, or would lose loads while running, if numLoads
+// is outside 1 to MaxNumLoads, or if the new name has no owners or one who
+// isn't on the resident list.
+func (q *LaundryQueue) UpdateItem(id, name string, numLoads int) bool {
+	if numLoads < 1 || numLoads > MaxNumLoads {
+		return false
+	}
+	owners := ParseOwners(name)
+	if len(owners) == 0 {
+		return false
+	}
+	for _, owner := range owners {
+		if !IsAllowed(q.opts.Allowlist, owner) {
+			return false
+		}
+	}
+
+	q.mu.Lock()
+	defer q.unlock()
+
+	for _, item := range q.items {
+		if item.ID != id {
+			continue
+		}
+		switch item.Status {
+		case StatusWaiting:
+		case StatusInProgress:
+			if numLoads < item.NumLoads {
+				return false
+			}
+		default:
+			return false
+		}
+		item.setOwners(name)
+		item.NumLoads = numLoads
+		q.publish(EventItemUpdated, item)
+		return true
+	}
+	return false
+}
+
+// StartTimer starts the timer for a queued person on the first free machine.
+// It returns ErrNotFound, ErrAlreadyCompleted, or ErrNotWaiting when the item
+// can't be started, and a *StartError when one of CanStart's rules refuses it.
+// Repeating a start with the same duration within DuplicateStartWindow
+// succeeds without restarting the timer.
+func (q *LaundryQueue) StartTimer(id string, duration int) error {
+	return q.StartTimerDelayed(id, duration, 0)
+}
+
+// StartTimerDelayed reserves the machine for a queued person now but starts
+// counting down their load only after delayMinutes, giving them time to finish
+// loading. It returns the same errors as StartTimer.
+func (q *LaundryQueue) StartTimerDelayed(id string, duration, delayMinutes int) error {
+	q.mu.Lock()
+	defer q.unlock()
+
+	for _, item := range q.items {
+		if item.ID != id {
+			continue
+		}
+		switch item.Status {
+		case StatusWaiting:
+			start := time.Now().Add(minutes(delayMinutes))
+			if err := q.canStart(ParseOwners(item.Name), start); err != nil {
+				return err
+			}
+			item.MachineID = freeMachines(q.items, q.Machines())[0]
+			item.StartTime = &start
+			item.Duration = q.clampDuration(duration)
+			item.Status = StatusInProgress
+			item.startedAt = time.Now()
+			q.freezeIfPaused(item, item.startedAt)
+			q.scheduleCompletion(item)
+			q.publish(EventTimerStarted, item)
+			return nil
+		case StatusCompleted:
+			return ErrAlreadyCompleted
+		case StatusInProgress:
+			// A repeat of the start that just happened, typically a
+			// double-click, succeeds without starting the timer again
+			if item.Duration == q.clampDuration(duration) && time.Since(item.startedAt) < DuplicateStartWindow {
+				return nil
+			}
+			return ErrNotWaiting
+		default:
+			return ErrNotWaiting
+		}
+	}
+	return ErrNotFound
+}
+
+// CanStart reports whether name could start a load right now and, if not,
+// why. StartTimer, StartTimerDelayed and AddAndStart apply the same rules and
+// refuse with a *StartError carrying the same reason.
+func (q *LaundryQueue) CanStart(name string) (bool, string) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var refused *StartError
+	if errors.As(q.canStart(ParseOwners(name), time.Now()), &refused) {
+		return false, refused.Reason
+	}
+	return true, ""
+}
+
+// canStart returns a *StartError if owners may not start a load at start:
+// one of them isn't on the allowlist, none of them is already waiting while
+// others are, so they join the back of the line instead, every machine is
+// taken, or RejectBunchedStarts is set and start falls inside the stagger gap.
+// Callers must hold the lock.
+func (q *LaundryQueue) canStart(owners []string, start time.Time) error {
+	for _, owner := range owners {
+		if !IsAllowed(q.opts.Allowlist, owner) {
+			return &StartError{ErrNotAllowed, fmt.Sprintf("%s is not on the resident list", owner)}
+		}
+	}
+
+	waiting := waitingItems(q.items, q.opts.TierWeights)
+	inLine := false
+	for _, item := range waiting {
+		for _, owner := range owners {
+			inLine = inLine || item.HasOwner(owner)
+		}
+	}
+	if !inLine && len(waiting) > 0 {
+		return &StartError{ErrOthersWaiting, "Others are already waiting; join the queue"}
+	}
+
+	if len(freeMachines(q.items, q.Machines())) == 0 {
+		return &StartError{ErrNoFreeMachine, "Every machine is in use"}
+	}
+	if gap := q.staggerMinutes(start); q.opts.RejectBunchedStarts && gap > 0 {
+		return &StartError{ErrStartTooSoon, fmt.Sprintf("Another load started moments ago; start in %d minutes", gap)}
+	}
+	return nil
 }
 
-// GetRemainingMinutes returns how many minutes are left
-func (q *QueueItem) GetRemainingMinutes() int {
-	if q.Status != StatusInProgress || q.StartTime == nil || q.Duration == 0 {
+// StaggerMinutes returns how many more minutes a start delayed by
+// delayMinutes should wait to come StartGap after the most recent start, or 0
+// if it is already clear of the gap
+func (q *LaundryQueue) StaggerMinutes(delayMinutes int) int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.staggerMinutes(time.Now().Add(minutes(delayMinutes)))
+}
+
+// staggerMinutes returns the minutes, rounded up, by which start falls inside
+// the gap after the most recent start. Callers must hold the lock.
+func (q *LaundryQueue) staggerMinutes(start time.Time) int {
+	if q.opts.StartGap <= 0 {
 		return 0
 	}
-	endTime := q.StartTime.Add(time.Duration(q.Duration) * time.Minute)
-	remaining := time.Until(endTime).Minutes()
-	if remaining < 0 {
+
+	var latest time.Time
+	for _, item := range q.items {
+		if item.Status == StatusInProgress && item.StartTime != nil && item.StartTime.After(latest) {
+			latest = *item.StartTime
+		}
+	}
+	if latest.IsZero() {
 		return 0
 	}
-	return int(remaining)
+	if short := latest.Add(q.opts.StartGap).Sub(start); short > 0 {
+		return int(math.Ceil(short.Minutes()))
+	}
+	return 0
 }
 
-// IsTimerExpired checks if the timer has expired
-func (q *QueueItem) IsTimerExpired() bool {
-	return q.GetRemainingMinutes() <= 0
-}
+// PauseAll freezes the countdown of every load on the machine, such as in an
+// emergency, keeping each one's remaining time, and the transit timeout of
+// every finished wash. Loads started while paused stay frozen until ResumeAll.
+func (q *LaundryQueue) PauseAll() {
+	q.mu.Lock()
+	defer q.unlock()
 
-// ShouldAutoRemove checks if completed item should be removed
-func (q *QueueItem) ShouldAutoRemove() bool {
-	if q.Status != StatusCompleted || q.CompletedAt == nil {
-		return false
+	if q.paused {
+		return
 	}
-	return time.Since(*q.CompletedAt) > AutoRemoveDelay
+	now := time.Now()
+	q.paused = true
+	for _, item := range q.items {
+		q.stopTimer(item)
+		if q.freezeIfPaused(item, now) {
+			q.publish(EventItemUpdated, item)
+		}
+	}
+	q.touch(now)
 }
 
-// LaundryQueue manages the queue
-type LaundryQueue struct {
-	mu    sync.RWMutex
-	items []*QueueItem
-}
+// ResumeAll restarts every countdown frozen by PauseAll from where it stopped
+func (q *LaundryQueue) ResumeAll() {
+	q.mu.Lock()
+	defer q.unlock()
 
-// NewLaundryQueue creates a new queue
-func NewLaundryQueue() *LaundryQueue {
-	queue := &LaundryQueue{
-		items: make([]*QueueItem, 0),
+	if !q.paused {
+		return
 	}
-	go queue.backgroundWorker()
-	return queue
+	now := time.Now()
+	q.paused = false
+	for _, item := range q.items {
+		if item.PausedAt == nil {
+			continue
+		}
+		paused := now.Sub(*item.PausedAt)
+		if item.Status == StatusInProgress && item.StartTime != nil {
+			start := item.StartTime.Add(paused)
+			item.StartTime = &start
+		}
+		if item.Status == StatusTransit && item.TransitAt != nil {
+			transit := item.TransitAt.Add(paused)
+			item.TransitAt = &transit
+		}
+		item.PausedAt = nil
+		q.scheduleCompletion(item)
+		q.publish(EventItemUpdated, item)
+	}
+	q.touch(now)
 }
 
-func (q *LaundryQueue) backgroundWorker() {
-	ticker := time.NewTicker(BackgroundWorkerInterval)
-	defer ticker.Stop()
+// IsPaused reports whether PauseAll has frozen the room
+func (q *LaundryQueue) IsPaused() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
 
-	for range ticker.C {
-		q.mu.Lock()
-		newItems := make([]*QueueItem, 0)
-		for _, item := range q.items {
-			if item.Status == StatusInProgress && item.IsTimerExpired() {
-				item.Status = StatusCompleted
-				now := time.Now()
-				item.CompletedAt = &now
-			}
+	return q.paused
+}
 
-			if !item.ShouldAutoRemove() {
-				newItems = append(newItems, item)
-			}
-		}
-		q.items = newItems
-		q.mu.Unlock()
+// freezeIfPaused stops an in-progress item's countdown, or a transit item's
+// timeout, at now if the room is paused, reporting whether it did. Callers
+// must hold the lock.
+func (q *LaundryQueue) freezeIfPaused(item *QueueItem, now time.Time) bool {
+	active := item.Status == StatusInProgress || item.Status == StatusTransit
+	if q.paused && active && item.PausedAt == nil {
+		item.PausedAt = &now
+		return true
 	}
+	return false
 }
 
-// AddToQueue adds a new person to the queue
-func (q *LaundryQueue) AddToQueue(name string, numLoads int) *QueueItem {
+// CancelDelayedStart returns a load whose delayed start hasn't begun to the
+// waiting list, keeping its place in line
+func (q *LaundryQueue) CancelDelayedStart(id string) bool {
 	q.mu.Lock()
-	defer q.mu.Unlock()
+	defer q.unlock()
 
-	item := &QueueItem{
-		ID:       time.Now().Format("20060102150405") + "-" + name,
-		Name:     name,
-		Status:   StatusWaiting,
-		NumLoads: numLoads,
-		QueuedAt: time.Now(),
+	for _, item := range q.items {
+		if item.ID == id && item.IsPending() {
+			item.Status = StatusWaiting
+			item.StartTime = nil
+			item.Duration = 0
+			item.MachineID = 0
+			item.PausedAt = nil
+			item.preStartNotified = false
+			q.stopTimer(item)
+			q.publish(EventItemUpdated, item)
+			return true
+		}
 	}
-	q.items = append(q.items, item)
-	return item
+	return false
 }
 
-// StartTimer starts the timer for a queued person
-func (q *LaundryQueue) StartTimer(id string, duration int) bool {
+// StartDrying starts the dryer timer for a wash that is in transit
+func (q *LaundryQueue) StartDrying(id string, duration int) bool {
 	q.mu.Lock()
-	defer q.mu.Unlock()
+	defer q.unlock()
 
 	for _, item := range q.items {
-		if item.ID == id && item.Status == StatusWaiting {
+		if item.ID == id && item.Status == StatusTransit {
 			now := time.Now()
 			item.StartTime = &now
-			item.Duration = duration
+			item.Duration = q.clampDuration(duration)
 			item.Status = StatusInProgress
+			item.TransitAt = nil
+			item.PausedAt = nil
+			item.MachineID = 0
+			item.Drying = true
+			q.freezeIfPaused(item, now)
+			q.scheduleCompletion(item)
+			q.publish(EventTimerStarted, item)
 			return true
 		}
 	}
 	return false
 }
 
-// AddAndStart adds a new person and immediately starts their timer
-func (q *LaundryQueue) AddAndStart(name string, duration int, numLoads int) *QueueItem {
+// Requeue creates a fresh waiting entry for the owners of a completed item,
+// for a second wash or dryer load. It returns false if the item is not found
+// or has not completed.
+func (q *LaundryQueue) Requeue(id string) (*QueueItem, bool) {
+	q.mu.Lock()
+	defer q.unlock()
+
+	var done *QueueItem
+	for _, item := range q.items {
+		if item.ID == id && item.Status == StatusCompleted {
+			done = item
+			break
+		}
+	}
+	if done == nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	item := &QueueItem{
+		Status:   StatusWaiting,
+		NumLoads: done.NumLoads,
+		Tier:     done.Tier,
+		QueuedAt: now,
+	}
+	owners := done.Name
+	if len(done.Owners) > 0 {
+		owners = strings.Join(done.Owners, ",")
+	}
+	item.setOwners(owners)
+	item.ID = q.nextID(item)
+
+	sameDay := done.CompletedAt != nil && done.CompletedAt.Format("2006-01-02") == now.Format("2006-01-02")
+	if q.opts.RequeuePriority && sameDay {
+		q.insertBeforeWaiting(item)
+	} else {
+		q.items = append(q.items, item)
+	}
+	q.recordPeak(now)
+	q.totalAdds.Add(1)
+	q.publish(EventItemAdded, item)
+	return item, true
+}
+
+// MoveToPosition moves a waiting item to the given 1-based waiting position,
+// shifting the others, such as to undo someone cutting in line. Running and
+// finished items keep their places. It returns false if the item isn't
+// waiting, the position is out of range, or the position belongs to another
+// tier, since tier priority would undo the move.
+func (q *LaundryQueue) MoveToPosition(id string, position int) bool {
+	q.mu.Lock()
+	defer q.unlock()
+
+	waiting := waitingItems(q.items, q.opts.TierWeights)
+	if position < 1 || position > len(waiting) {
+		return false
+	}
+	from := -1
+	for i, item := range waiting {
+		if item.ID == id {
+			from = i
+			break
+		}
+	}
+	if from < 0 || tierWeight(q.opts.TierWeights, waiting[from].Tier) != tierWeight(q.opts.TierWeights, waiting[position-1].Tier) {
+		return false
+	}
+
+	item := waiting[from]
+	reordered := append(append([]*QueueItem{}, waiting[:from]...), waiting[from+1:]...)
+	reordered = append(reordered[:position-1], append([]*QueueItem{item}, reordered[position-1:]...)...)
+
+	// Refill only the slots waiting items occupy, in their new order
+	next := 0
+	for i, other := range q.items {
+		if other.Status == StatusWaiting {
+			q.items[i] = reordered[next]
+			next++
+		}
+	}
+	q.publish(EventItemUpdated, item)
+	return true
+}
+
+// insertBeforeWaiting places item ahead of every waiting item. Callers must hold the lock.
+func (q *LaundryQueue) insertBeforeWaiting(item *QueueItem) {
+	for i, other := range q.items {
+		if other.Status == StatusWaiting {
+			q.items = append(q.items[:i], append([]*QueueItem{item}, q.items[i:]...)...)
+			return
+		}
+	}
+	q.items = append(q.items, item)
+}
+
+// AddAndStart adds a new person and immediately starts their timer on the
+// first free machine. It returns a *StartError, adding nobody, when one of
+// CanStart's rules refuses the load.
+func (q *LaundryQueue) AddAndStart(name string, duration int, numLoads int, tier string) (*QueueItem, error) {
+	return q.AddAndStartWithDetails(name, duration, numLoads, tier, ItemDetails{})
+}
+
+// AddAndStartWithDetails adds and starts a load like AddAndStart, with details
+// set before the item is published. It also returns an ErrInvalidMetadata
+// error, adding nobody, if the metadata is over the limits.
+func (q *LaundryQueue) AddAndStartWithDetails(name string, duration int, numLoads int, tier string, details ItemDetails) (*QueueItem, error) {
+	if err := ValidateMetadata(details.Metadata); err != nil {
+		return nil, err
+	}
+
 	q.mu.Lock()
-	defer q.mu.Unlock()
+	defer q.unlock()
 
 	now := time.Now()
+	if err := q.canStart(ParseOwners(name), now); err != nil {
+		return nil, err
+	}
+
 	item := &QueueItem{
-		ID:        time.Now().Format("20060102150405") + "-" + name,
-		Name:      name,
 		Status:    StatusInProgress,
 		StartTime: &now,
-		Duration:  duration,
+		Duration:  q.clampDuration(duration),
 		NumLoads:  numLoads,
+		Tier:      tier,
 		QueuedAt:  now,
+		MachineID: freeMachines(q.items, q.Machines())[0],
 	}
+	item.setOwners(name)
+	details.apply(item)
+	item.ID = q.nextID(item)
+	q.freezeIfPaused(item, now)
+	q.scheduleCompletion(item)
 	q.items = append(q.items, item)
-	return item
+	q.totalAdds.Add(1)
+	q.publish(EventItemAdded, item)
+	q.publish(EventTimerStarted, item)
+	return item, nil
+}
+
+// CompleteExpiredAndNotify finishes every expired load and publishes
+// EventNextUp for whoever is now next up in one pass under the lock, so the
+// events arrive in order. It returns how many loads it finished and the newly
+// announced items, which is empty if nobody new was called up.
+func (q *LaundryQueue) CompleteExpiredAndNotify() (int, []QueueItem) {
+	q.mu.Lock()
+	defer q.unlock()
+
+	now := time.Now()
+	count := 0
+	for _, item := range q.items {
+		if q.loadFinished(item, now) {
+			q.finishLoad(item, now)
+			count++
+		}
+	}
+	return count, q.nextUp(now)
+}
+
+// CompleteNow marks a running load completed before its timer runs out, such
+// as after a quick cycle, and announces whoever is now next up. It returns
+// false if the item isn't found or isn't running; a delayed start that hasn't
+// begun is cancelled with CancelDelayedStart instead.
+func (q *LaundryQueue) CompleteNow(id string) bool {
+	q.mu.Lock()
+	defer q.unlock()
+
+	for _, item := range q.items {
+		if item.ID == id && item.Status == StatusInProgress && !item.IsPending() {
+			q.completeEarly(item, time.Now())
+			return true
+		}
+	}
+	return false
+}
+
+// ShortenTimer cuts an in-progress load's remaining time down to
+// newRemainingMinutes, for someone who picked too long a cycle. Whole minutes
+// already elapsed are kept, so the new end is never in the past, and a
+// remaining time of zero or less completes the load at once. It returns false
+// if the load isn't running or the change would not shorten it.
+func (q *LaundryQueue) ShortenTimer(id string, newRemainingMinutes int) bool {
+	q.mu.Lock()
+	defer q.unlock()
+
+	for _, item := range q.items {
+		if item.ID != id || item.Status != StatusInProgress || item.IsPending() {
+			continue
+		}
+		if newRemainingMinutes <= 0 {
+			q.completeEarly(item, time.Now())
+			return true
+		}
+
+		elapsed := int(math.Ceil(item.clock().Sub(*item.StartTime).Minutes()))
+		duration := elapsed + newRemainingMinutes
+		if duration >= item.Duration {
+			return false
+		}
+		item.Duration = duration
+		q.scheduleCompletion(item)
+		q.publish(EventItemUpdated, item)
+		return true
+	}
+	return false
+}
+
+// completeEarly marks a running load completed before its timer runs out and
+// announces whoever is now next up. Callers must hold the lock.
+func (q *LaundryQueue) completeEarly(item *QueueItem, now time.Time) {
+	q.stopTimer(item)
+	item.Status = StatusCompleted
+	item.CompletedAt = &now
+	item.PausedAt = nil
+	q.publish(EventItemCompleted, item)
+	q.nextUp(now)
 }
 
 // GetAll returns all queue items
@@ -157,59 +1735,347 @@ func (q *LaundryQueue) GetAll() []*QueueItem {
 	return result
 }
 
-// HasActiveLoad checks if anyone has a load currently running
-func (q *LaundryQueue) HasActiveLoad() bool {
+// AddedBetween returns the items queued within [start, end), including completed items still present
+func (q *LaundryQueue) AddedBetween(start, end time.Time) []*QueueItem {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
 
+	result := make([]*QueueItem, 0)
 	for _, item := range q.items {
-		if item.Status == StatusInProgress && !item.IsTimerExpired() {
-			return true
+		if !item.QueuedAt.Before(start) && item.QueuedAt.Before(end) {
+			result = append(result, item)
 		}
 	}
-	return false
+	return result
 }
 
-// HasQueueItems checks if there are any items in the queue (waiting or in progress)
-func (q *LaundryQueue) HasQueueItems() bool {
+// GetFreeMachines returns the IDs of the machines nobody is running a load on
+// or has reserved for a delayed start, in order
+func (q *LaundryQueue) GetFreeMachines() []int {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
 
-	for _, item := range q.items {
-		if item.Status == StatusWaiting || (item.Status == StatusInProgress && !item.IsTimerExpired()) {
-			return true
-		}
-	}
-	return false
+	return freeMachines(q.items, q.Machines())
+}
+
+// AvailableMachineCount returns how many machines are free to start a load on
+func (q *LaundryQueue) AvailableMachineCount() int {
+	return len(q.GetFreeMachines())
 }
 
-// GetQueuePosition returns the position of a person in the waiting queue
-func (q *LaundryQueue) GetQueuePosition(id string) int {
+// NextUp returns the waiting item whose turn is next, respecting tier
+// priority, or nil if nobody is waiting
+func (q *LaundryQueue) NextUp() *QueueItem {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
 
-	position := 0
+	if waiting := waitingItems(q.items, q.opts.TierWeights); len(waiting) > 0 {
+		return waiting[0]
+	}
+	return nil
+}
+
+// RemoveByName removes name (case-insensitive) from every item they own,
+// including running loads, and returns how many items were affected. Shared
+// loads stay in the queue for their remaining owners.
+func (q *LaundryQueue) RemoveByName(name string) int {
+	q.mu.Lock()
+	defer q.unlock()
+
+	kept := make([]*QueueItem, 0, len(q.items))
+	affected := 0
 	for _, item := range q.items {
-		if item.Status == StatusWaiting {
-			position++
-			if item.ID == id {
-				return position
+		if !item.HasOwner(name) {
+			kept = append(kept, item)
+			continue
+		}
+
+		affected++
+		remaining := make([]string, 0, len(item.Owners))
+		for _, owner := range item.Owners {
+			if !strings.EqualFold(owner, name) {
+				remaining = append(remaining, owner)
 			}
 		}
+		if len(remaining) == 0 {
+			q.stopTimer(item)
+			q.publish(EventItemRemoved, item)
+			continue
+		}
+		item.setOwners(strings.Join(remaining, ","))
+		q.publish(EventItemUpdated, item)
+		kept = append(kept, item)
+	}
+	q.items = kept
+	return affected
+}
+
+// WaitIfJoinedNow estimates the minutes a resident joining now would wait for
+// their turn, accounting for every machine. It is 0 when a machine is free
+// and nobody is waiting.
+func (q *LaundryQueue) WaitIfJoinedNow() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	now := time.Now()
+	joiner := &QueueItem{Status: StatusWaiting, NumLoads: 1, Tier: TierResident}
+	items := append(append(make([]*QueueItem, 0, len(q.items)+1), q.items...), joiner)
+	return ceilMinutes(EstimateStarts(items, q.opts.TierWeights, q.Machines(), q.LoadMinutes(), now)[joiner.ID].Sub(now))
+}
+
+// StateAt reconstructs the queue as it was at the given moment. It returns
+// ErrNoTimeline if the timeline is disabled and ErrBeforeTimeline if the
+// moment is older than the retention window.
+func (q *LaundryQueue) StateAt(at time.Time) ([]QueueItem, error) {
+	if q.timeline == nil {
+		return nil, ErrNoTimeline
+	}
+	return q.timeline.StateAt(at)
+}
+
+// GetHistory returns copies of the most recently completed loads, newest
+// first, or an empty list when the history is disabled
+func (q *LaundryQueue) GetHistory() []*QueueItem {
+	if q.history == nil {
+		return make([]*QueueItem, 0)
 	}
-	return -1
+	return q.history.Items()
 }
 
 // Remove removes an item from the queue
 func (q *LaundryQueue) Remove(id string) bool {
 	q.mu.Lock()
-	defer q.mu.Unlock()
+	defer q.unlock()
 
 	for i, item := range q.items {
 		if item.ID == id {
 			q.items = append(q.items[:i], q.items[i+1:]...)
+			q.stopTimer(item)
+			q.publish(EventItemRemoved, item)
 			return true
 		}
 	}
 	return false
 }
+
+// ForecastEntry is an item's projected slot on a machine
+type ForecastEntry struct {
+	ID    string    `json:"id"`
+	Name  string    `json:"name"`
+	Start time.Time `json:"projected_start"`
+	End   time.Time `json:"projected_end"`
+}
+
+// Forecast is the projected state of the queue at a future moment. FreeAt is
+// when a washer is next free from that moment on.
+type Forecast struct {
+	At       time.Time       `json:"at"`
+	Running  []ForecastEntry `json:"running"`
+	Waiting  []ForecastEntry `json:"waiting"`
+	Finished []ForecastEntry `json:"finished"`
+	FreeAt   time.Time       `json:"free_at"`
+}
+
+// Forecast projects the queue d into the future, assuming running loads finish
+// on time and waiting items start when EstimateStarts expects, across every
+// machine. It is a read-only projection and does not modify the queue.
+func (q *LaundryQueue) Forecast(d time.Duration) Forecast {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	now := time.Now()
+	forecast := Forecast{
+		At:       now.Add(d),
+		Running:  make([]ForecastEntry, 0),
+		Waiting:  make([]ForecastEntry, 0),
+		Finished: make([]ForecastEntry, 0),
+	}
+
+	// busyUntil holds when each washer taken at the forecast moment frees up
+	busyUntil := make([]time.Time, 0, q.Machines())
+	place := func(entry ForecastEntry, washer bool) {
+		switch {
+		case !entry.End.After(forecast.At):
+			forecast.Finished = append(forecast.Finished, entry)
+			return
+		case entry.Start.After(forecast.At):
+			forecast.Waiting = append(forecast.Waiting, entry)
+		default:
+			forecast.Running = append(forecast.Running, entry)
+		}
+		if washer {
+			busyUntil = append(busyUntil, entry.End)
+		}
+	}
+
+	for _, item := range q.items {
+		if item.Status != StatusInProgress || item.StartTime == nil {
+			continue
+		}
+		end := item.EndTime()
+		if end.Before(now) {
+			end = now
+		}
+		// A delayed start holds its washer before its countdown begins
+		place(ForecastEntry{ID: item.ID, Name: item.Name, Start: *item.StartTime, End: end}, !item.Drying)
+	}
+
+	starts := EstimateStarts(q.items, q.opts.TierWeights, q.Machines(), q.LoadMinutes(), now)
+	for _, item := range waitingItems(q.items, q.opts.TierWeights) {
+		start := starts[item.ID]
+		end := start.Add(minutes(item.expectedMinutes(q.LoadMinutes())))
+		place(ForecastEntry{ID: item.ID, Name: item.Name, Start: start, End: end}, !start.After(forecast.At))
+	}
+
+	forecast.FreeAt = forecast.At
+	if len(busyUntil) >= q.Machines() {
+		forecast.FreeAt = busyUntil[0]
+		for _, end := range busyUntil {
+			if end.Before(forecast.FreeAt) {
+				forecast.FreeAt = end
+			}
+		}
+	}
+	return forecast
+}
+
+// QueueSummary counts queue items by state
+type QueueSummary struct {
+	Running          int   `json:"running"`
+	Waiting          int   `json:"waiting"`
+	InTransit        int   `json:"in_transit"`
+	Completed        int   `json:"completed"`
+	PeakWaiting      int   `json:"peak_waiting"`
+	PeakWaitingToday int   `json:"peak_waiting_today"`
+	PossiblyAbsent   int   `json:"possibly_absent"`
+	TotalAdds        int64 `json:"total_adds"`
+}
+
+// Summary returns the number of items in each state, the peak number of
+// people waiting at once, all-time and today, how many waiting items are
+// flagged as possibly absent, and how many items were added since startup
+func (q *LaundryQueue) Summary() QueueSummary {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var summary QueueSummary
+	for _, item := range q.items {
+		switch item.Status {
+		case StatusInProgress:
+			summary.Running++
+		case StatusWaiting:
+			summary.Waiting++
+			if item.PossiblyAbsent {
+				summary.PossiblyAbsent++
+			}
+		case StatusTransit:
+			summary.InTransit++
+		case StatusCompleted:
+			summary.Completed++
+		}
+	}
+
+	summary.PeakWaiting = q.peakWaiting
+	summary.TotalAdds = q.TotalAdds()
+	summary.PeakWaitingToday = summary.Waiting
+	if q.peakDay == time.Now().Format("2006-01-02") && q.dayPeakWaiting > summary.Waiting {
+		summary.PeakWaitingToday = q.dayPeakWaiting
+	}
+	return summary
+}
+
+// TotalAdds returns how many items have been added since startup
+func (q *LaundryQueue) TotalAdds() int64 {
+	return q.totalAdds.Load()
+}
+
+// NextFreeMinutes returns how many minutes until a machine is free, or 0 if one is free now
+func (q *LaundryQueue) NextFreeMinutes() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if len(freeMachines(q.items, q.Machines())) > 0 {
+		return 0
+	}
+	next := 0
+	for _, item := range q.items {
+		if remaining := item.minutesUntilFree(); item.holdsMachine() && (next == 0 || remaining < next) {
+			next = remaining
+		}
+	}
+	return next
+}
+
+// ExpiringSoon returns completed items that will be auto-removed within the
+// given window, soonest first, giving their owners a last chance to collect
+// their clothes. It is empty when auto-removal is disabled.
+func (q *LaundryQueue) ExpiringSoon(within time.Duration) []*QueueItem {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	expiring := make([]*QueueItem, 0)
+	if q.opts.DisableAutoRemove {
+		return expiring
+	}
+	for _, item := range q.items {
+		if item.Status == StatusCompleted && item.CompletedAt != nil && item.autoRemoveIn() <= within {
+			expiring = append(expiring, item)
+		}
+	}
+	sort.SliceStable(expiring, func(i, j int) bool {
+		return expiring[i].CompletedAt.Before(*expiring[j].CompletedAt)
+	})
+	return expiring
+}
+
+// FreedMachine records a load that recently finished and left the machine free
+type FreedMachine struct {
+	ItemID     string    `json:"item_id"`
+	MachineID  int       `json:"machine_id,omitempty"`
+	FreedBy    string    `json:"freed_by"`
+	FreedAt    time.Time `json:"freed_at"`
+	MinutesAgo int       `json:"minutes_ago"`
+}
+
+// RecentlyFreed returns washer loads that finished within the given window,
+// newest first. Completions followed by a newer start on the same machine are
+// skipped, since it has been taken again, as are dryer loads, which free no
+// washer.
+func (q *LaundryQueue) RecentlyFreed(within time.Duration) []FreedMachine {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	now := time.Now()
+	lastStart := make(map[int]time.Time)
+	for _, item := range q.items {
+		if item.Status == StatusInProgress && item.StartTime != nil && item.StartTime.After(lastStart[item.MachineID]) {
+			lastStart[item.MachineID] = *item.StartTime
+		}
+	}
+
+	freed := make([]FreedMachine, 0)
+	for _, item := range q.items {
+		if item.Drying {
+			continue
+		}
+		freedAt := item.CompletedAt
+		if item.Status == StatusTransit {
+			freedAt = item.TransitAt
+		}
+		if freedAt == nil || now.Sub(*freedAt) > within || !freedAt.After(lastStart[item.MachineID]) {
+			continue
+		}
+		freed = append(freed, FreedMachine{
+			ItemID:     item.ID,
+			MachineID:  item.MachineID,
+			FreedBy:    item.Name,
+			FreedAt:    *freedAt,
+			MinutesAgo: int(now.Sub(*freedAt).Minutes()),
+		})
+	}
+
+	sort.Slice(freed, func(i, j int) bool {
+		return freed[i].FreedAt.After(freed[j].FreedAt)
+	})
+	return freed
+}
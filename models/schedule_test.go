@@ -0,0 +1,1400 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequeueKeepsOwners(t *testing.T) {
+	q := NewLaundryQueueWithMachines(2)
+	defer q.Close()
+
+	// Both start before either requeues, as nobody may start ahead of a waiting item
+	loads := make([]*QueueItem, 0, 2)
+	for _, name := range []string{"Ann", "Bob, Cat"} {
+		done, err := q.AddAndStart(name, 30, 1, TierResident)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loads = append(loads, done)
+	}
+	for _, done := range loads {
+		name := done.Name
+		q.CompleteNow(done.ID)
+
+		again, ok := q.Requeue(done.ID)
+		if !ok {
+			t.Fatalf("Requeue(%q) failed", name)
+		}
+		if again.Name != done.Name || len(again.Owners) != len(done.Owners) {
+			t.Errorf("requeued %q as name %q owners %v", name, again.Name, again.Owners)
+		}
+		if again.ID == done.ID || again.Status != StatusWaiting {
+			t.Errorf("requeued %q as %s item %s, want a new waiting item", name, again.Status, again.ID)
+		}
+	}
+}
+
+// backdate moves every timestamp of the item with id d into the past, as if
+// all of it had happened d earlier
+func backdate(q *LaundryQueue, id string, d time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	shift := func(t *time.Time) *time.Time {
+		if t == nil {
+			return nil
+		}
+		moved := t.Add(-d)
+		return &moved
+	}
+	for _, item := range q.items {
+		if item.ID == id {
+			item.QueuedAt = item.QueuedAt.Add(-d)
+			item.StartTime = shift(item.StartTime)
+			item.TransitAt = shift(item.TransitAt)
+			item.CompletedAt = shift(item.CompletedAt)
+			item.PausedAt = shift(item.PausedAt)
+		}
+	}
+}
+
+// newMachineQueue returns a two-washer queue assuming 30 minute loads, with
+// a 60 minute load running and A (1 load), B (2 loads) and C (1 load) waiting
+func newMachineQueue(t *testing.T) (*LaundryQueue, map[string]*QueueItem) {
+	t.Helper()
+	q := NewLaundryQueueWithOptions(Options{Machines: 2, LoadMinutes: 30})
+	t.Cleanup(q.Close)
+
+	items := make(map[string]*QueueItem)
+	running, err := q.AddAndStart("R", 60, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items["R"] = running
+	items["A"] = q.AddToQueue("A", 1, TierResident)
+	items["B"] = q.AddToQueue("B", 2, TierResident)
+	items["C"] = q.AddToQueue("C", 1, TierResident)
+	return q, items
+}
+
+func TestETAMinutesAcrossMachines(t *testing.T) {
+	q, items := newMachineQueue(t)
+	all := q.GetAll()
+	now := time.Now()
+
+	for name, want := range map[string]int{"A": 0, "B": 30, "C": 60} {
+		if got := ETAMinutes(all, items[name], q.TierWeights(), q.Machines(), q.LoadMinutes(), now); got != want {
+			t.Errorf("ETAMinutes(%s) = %d, want %d", name, got, want)
+		}
+	}
+	if got := WaitForPosition(all, 4, q.TierWeights(), q.Machines(), q.LoadMinutes(), now); got != 90 {
+		t.Errorf("WaitForPosition(4) = %d, want 90", got)
+	}
+}
+
+func TestForecastUsesEveryMachine(t *testing.T) {
+	q, _ := newMachineQueue(t)
+	forecast := q.Forecast(45 * time.Minute)
+
+	names := func(entries []ForecastEntry) string {
+		list := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			list = append(list, entry.Name)
+		}
+		sort.Strings(list)
+		return strings.Join(list, ",")
+	}
+	if got := names(forecast.Running); got != "B,R" {
+		t.Errorf("running = %s, want B,R", got)
+	}
+	if got := names(forecast.Finished); got != "A" {
+		t.Errorf("finished = %s, want A", got)
+	}
+	if got := names(forecast.Waiting); got != "C" {
+		t.Errorf("waiting = %s, want C", got)
+	}
+	if free := time.Until(forecast.FreeAt); free < 59*time.Minute || free > 60*time.Minute {
+		t.Errorf("free in %s, want 60m when R finishes", free)
+	}
+}
+
+func TestNextUpAnnouncesEachFreedMachineOnce(t *testing.T) {
+	q := NewLaundryQueueWithMachines(2)
+	defer q.Close()
+
+	for _, name := range []string{"X", "Y"} {
+		if _, err := q.AddAndStart(name, 30, 1, TierResident); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"A", "B", "C"} {
+		q.AddToQueue(name, 1, TierResident)
+	}
+
+	q.mu.Lock()
+	for _, item := range q.items {
+		if item.Status == StatusInProgress {
+			started := item.StartTime.Add(-time.Hour)
+			item.StartTime = &started
+		}
+	}
+	q.mu.Unlock()
+
+	completed, announced := q.CompleteExpiredAndNotify()
+	if completed != 2 {
+		t.Fatalf("completed %d loads, want 2", completed)
+	}
+	names := make([]string, 0, len(announced))
+	for _, item := range announced {
+		names = append(names, item.Name)
+	}
+	if got := strings.Join(names, ","); got != "A,B" {
+		t.Errorf("announced %s, want A,B", got)
+	}
+
+	if _, again := q.CompleteExpiredAndNotify(); len(again) != 0 {
+		t.Errorf("announced %d items a second time", len(again))
+	}
+}
+
+func TestAddWithDetailsPublishesThemWithTheItem(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	added := make(chan Event, 1)
+	unsubscribe := q.Events().Subscribe(func(event Event) { added <- event }, EventItemAdded)
+	defer unsubscribe()
+
+	details := ItemDetails{Metadata: map[string]string{"room": "12"}, AssistedBy: " Pat "}
+	if _, err := q.AddAndStartWithDetails("Ann", 30, 1, TierResident, details); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-added:
+		if event.Item.Metadata["room"] != "12" || event.Item.AssistedBy != "Pat" {
+			t.Errorf("added event item has metadata %v, assisted by %q", event.Item.Metadata, event.Item.AssistedBy)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no item_added event")
+	}
+
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxMetadataEntries; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+	if _, err := q.AddToQueueWithDetails("Bob", 1, TierResident, ItemDetails{Metadata: tooMany}); err == nil {
+		t.Error("added an item with too much metadata")
+	}
+	if len(q.GetAll()) != 1 {
+		t.Errorf("queue has %d items, want 1", len(q.GetAll()))
+	}
+}
+
+func TestForecastMatchesHandComputedSchedule(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{LoadMinutes: 30})
+	defer q.Close()
+
+	// One washer: R runs 0-40, A (one load) 40-70, B (two loads) 70-130
+	running, err := q.AddAndStart("R", 40, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.AddToQueue("A", 1, TierResident)
+	q.AddToQueue("B", 2, TierResident)
+	forecast := q.Forecast(60 * time.Minute)
+
+	at := func(minutes int) time.Time { return running.StartTime.Add(time.Duration(minutes) * time.Minute) }
+	near := func(got, want time.Time) bool { return got.Sub(want).Abs() < time.Second }
+
+	if len(forecast.Finished) != 1 || forecast.Finished[0].Name != "R" {
+		t.Errorf("finished = %+v, want R", forecast.Finished)
+	}
+	if len(forecast.Running) != 1 || forecast.Running[0].Name != "A" ||
+		!near(forecast.Running[0].Start, at(40)) || !near(forecast.Running[0].End, at(70)) {
+		t.Errorf("running = %+v, want A from 40 to 70", forecast.Running)
+	}
+	if len(forecast.Waiting) != 1 || forecast.Waiting[0].Name != "B" ||
+		!near(forecast.Waiting[0].Start, at(70)) || !near(forecast.Waiting[0].End, at(130)) {
+		t.Errorf("waiting = %+v, want B from 70 to 130", forecast.Waiting)
+	}
+	if !near(forecast.FreeAt, at(70)) {
+		t.Errorf("free at %v, want %v", forecast.FreeAt, at(70))
+	}
+}
+
+func TestHigherTierOutranksEarlierLowerTier(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	for _, add := range []struct{ name, tier string }{
+		{"Guest", TierGuest}, {"Res1", TierResident}, {"Staff", TierStaff}, {"Res2", TierResident},
+	} {
+		q.AddToQueue(add.name, 1, add.tier)
+	}
+
+	waiting := waitingItems(q.GetAll(), q.TierWeights())
+	names := make([]string, 0, len(waiting))
+	for _, item := range waiting {
+		names = append(names, item.Name)
+	}
+	if got := strings.Join(names, ","); got != "Staff,Res1,Res2,Guest" {
+		t.Errorf("waiting order %s, want Staff,Res1,Res2,Guest", got)
+	}
+}
+
+func TestTierWeightsAreCopiedFromOptions(t *testing.T) {
+	weights := map[string]int{TierGuest: 0, TierResident: 1, TierStaff: 2}
+	q := NewLaundryQueueWithOptions(Options{TierWeights: weights})
+	defer q.Close()
+
+	staff := q.AddToQueue("Staff", 1, TierStaff)
+	guest := q.AddToQueue("Guest", 1, TierGuest)
+
+	// Changing the caller's map, or the accessor's copy, must not reorder the line
+	weights[TierStaff] = -1
+	q.TierWeights()[TierStaff] = -1
+
+	positions := WaitingPositions(q.GetAll(), q.TierWeights())
+	if positions[guest.ID] != 1 || positions[staff.ID] != 2 {
+		t.Errorf("positions %v, want Guest 1 and Staff 2", positions)
+	}
+	if next := q.NextUp(); next == nil || next.ID != guest.ID {
+		t.Errorf("NextUp() = %v, want Guest", next)
+	}
+}
+
+// find returns a copy of the queue's item with id, or nil
+func find(q *LaundryQueue, id string) *QueueItem {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	for _, item := range q.items {
+		if item.ID == id {
+			found := *item
+			return &found
+		}
+	}
+	return nil
+}
+
+func TestTransitTimeoutAndDrying(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{TransitTimeout: 10 * time.Minute, DisableAutoRemove: true})
+	defer q.Close()
+
+	washes := make([]*QueueItem, 2)
+	for i, name := range []string{"Dry", "Forgot"} {
+		wash, err := q.AddAndStart(name, 30, 1, TierResident)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backdate(q, wash.ID, time.Hour)
+		washes[i] = wash
+	}
+	q.CompleteExpiredAndNotify()
+	for _, wash := range washes {
+		if got := find(q, wash.ID).Status; got != StatusTransit {
+			t.Fatalf("finished wash %s is %s, want in transit", wash.Name, got)
+		}
+	}
+
+	if !q.StartDrying(washes[0].ID, 40) {
+		t.Fatal("StartDrying failed for a load in transit")
+	}
+	if dry := find(q, washes[0].ID); dry.Status != StatusInProgress || !dry.Drying {
+		t.Errorf("dryer load is %s, drying %v", dry.Status, dry.Drying)
+	}
+
+	backdate(q, washes[1].ID, 11*time.Minute)
+	q.sweep()
+	if got := find(q, washes[1].ID).Status; got != StatusCompleted {
+		t.Errorf("wash left in transit past the timeout is %s, want completed", got)
+	}
+
+	backdate(q, washes[0].ID, time.Hour)
+	q.CompleteExpiredAndNotify()
+	if got := find(q, washes[0].ID).Status; got != StatusCompleted {
+		t.Errorf("finished dryer load is %s, want completed without transit", got)
+	}
+}
+
+func TestAddedBetweenUsesQueuedAt(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	for name, ago := range map[string]time.Duration{"A": 3 * time.Hour, "B": 2 * time.Hour, "C": 0} {
+		backdate(q, q.AddToQueue(name, 1, TierResident).ID, ago)
+	}
+	queuedAt := make(map[string]time.Time)
+	for _, item := range q.GetAll() {
+		queuedAt[item.Name] = item.QueuedAt
+	}
+
+	names := func(items []*QueueItem) string {
+		list := make([]string, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.Name)
+		}
+		sort.Strings(list)
+		return strings.Join(list, ",")
+	}
+	// The range includes its start and excludes its end
+	if got := names(q.AddedBetween(queuedAt["B"], queuedAt["C"])); got != "B" {
+		t.Errorf("[B, C) = %s, want B", got)
+	}
+	if got := names(q.AddedBetween(queuedAt["A"].Add(-time.Minute), time.Now().Add(time.Minute))); got != "A,B,C" {
+		t.Errorf("whole range = %s, want A,B,C", got)
+	}
+	if got := names(q.AddedBetween(queuedAt["A"].Add(time.Minute), queuedAt["B"])); got != "" {
+		t.Errorf("gap between A and B = %s, want nothing", got)
+	}
+}
+
+func TestLoadCapForceCompletes(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{MaxLoadDuration: time.Hour, Machines: 2, DisableAutoRemove: true})
+	defer q.Close()
+
+	capped, err := q.AddAndStart("Long", 300, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capped.Duration != 60 {
+		t.Errorf("300 minute load got duration %d, want the 60 minute cap", capped.Duration)
+	}
+
+	// A load started before the cap was configured still stops at the cap
+	legacy, err := q.AddAndStart("Legacy", 60, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.mu.Lock()
+	q.items[len(q.items)-1].Duration = 300
+	q.mu.Unlock()
+
+	backdate(q, legacy.ID, 59*time.Minute)
+	if completed, _ := q.CompleteExpiredAndNotify(); completed != 0 {
+		t.Fatalf("completed %d loads before the cap", completed)
+	}
+	backdate(q, legacy.ID, 2*time.Minute)
+	if completed, _ := q.CompleteExpiredAndNotify(); completed != 1 || find(q, legacy.ID).Status != StatusCompleted {
+		t.Errorf("load past the cap not completed (completed %d)", completed)
+	}
+}
+
+// runningFor returns an in-progress item of duration minutes with remaining
+// minutes (and a few seconds) left
+func runningFor(duration, remaining int) *QueueItem {
+	start := time.Now().Add(time.Duration(remaining-duration)*time.Minute + 30*time.Second)
+	return &QueueItem{Status: StatusInProgress, StartTime: &start, Duration: duration}
+}
+
+func TestUrgencyByRemainingMinutes(t *testing.T) {
+	tests := []struct {
+		item *QueueItem
+		want string
+	}{
+		{&QueueItem{Status: StatusWaiting}, ""},
+		{runningFor(60, 30), UrgencyRunning},
+		{runningFor(60, 11), UrgencyRunning},
+		{runningFor(60, 10), UrgencySoon},
+		{runningFor(60, 3), UrgencySoon},
+		{runningFor(60, 2), UrgencyFinishing},
+		{runningFor(60, 1), UrgencyFinishing},
+		{runningFor(60, -5), UrgencyComplete},
+		{&QueueItem{Status: StatusCompleted}, UrgencyComplete},
+	}
+	for _, tt := range tests {
+		if got := tt.item.Urgency(DefaultUrgencySoonMinutes, DefaultUrgencyFinishingMinutes); got != tt.want {
+			t.Errorf("%s with %d minutes left: urgency %q, want %q", tt.item.Status, tt.item.GetRemainingMinutes(), got, tt.want)
+		}
+	}
+
+	q := NewLaundryQueueWithOptions(Options{UrgencySoonMinutes: 20, UrgencyFinishingMinutes: 5})
+	defer q.Close()
+	soon, finishing := q.UrgencyMinutes()
+	for remaining, want := range map[int]string{21: UrgencyRunning, 15: UrgencySoon, 5: UrgencyFinishing} {
+		if got := runningFor(60, remaining).Urgency(soon, finishing); got != want {
+			t.Errorf("%d minutes left with thresholds 20 and 5: urgency %q, want %q", remaining, got, want)
+		}
+	}
+}
+
+func TestCompleteExpiredFinishesEveryExpiredLoad(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{Machines: 3, DisableAutoRemove: true})
+	defer q.Close()
+
+	completed := make(chan Event, subscriberBuffer)
+	unsubscribe := q.Events().Subscribe(func(event Event) { completed <- event }, EventItemCompleted)
+	defer unsubscribe()
+
+	for i, name := range []string{"A", "B", "C"} {
+		item, err := q.AddAndStart(name, 30, 1, TierResident)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// C is still running
+		if i < 2 {
+			backdate(q, item.ID, time.Hour)
+		}
+	}
+
+	count, _ := q.CompleteExpiredAndNotify()
+	if count != 2 {
+		t.Errorf("completed %d loads, want 2", count)
+	}
+	got := make([]string, 0, 2)
+	for len(got) < 2 {
+		select {
+		case event := <-completed:
+			got = append(got, event.Item.Name)
+		case <-time.After(time.Second):
+			t.Fatalf("completion events %v, want A and B", got)
+		}
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != "A,B" {
+		t.Errorf("completion events %v, want A and B", got)
+	}
+	if summary := q.Summary(); summary.Running != 1 {
+		t.Errorf("%d loads still running, want 1", summary.Running)
+	}
+}
+
+func TestDisableAutoRemoveKeepsCompletedItems(t *testing.T) {
+	for _, disabled := range []bool{false, true} {
+		q := NewLaundryQueueWithOptions(Options{DisableAutoRemove: disabled})
+		item, err := q.AddAndStart("Ann", 30, 1, TierResident)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.CompleteNow(item.ID)
+		backdate(q, item.ID, 24*time.Hour)
+		q.sweep()
+
+		if kept := find(q, item.ID) != nil; kept != disabled {
+			t.Errorf("auto-remove disabled %v: day-old completed item kept %v", disabled, kept)
+		}
+		q.Close()
+	}
+}
+
+func TestWaitForPositionGrowsWithPosition(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{LoadMinutes: 40})
+	defer q.Close()
+
+	if _, err := q.AddAndStart("Runner", 55, 1, TierResident); err != nil {
+		t.Fatal(err)
+	}
+	for i, loads := range []int{2, 1, 3, 1} {
+		q.AddToQueue(string(rune('A'+i)), loads, TierResident)
+	}
+
+	all := q.GetAll()
+	now := time.Now()
+	previous := -1
+	for position := 1; position <= 6; position++ {
+		wait := WaitForPosition(all, position, q.TierWeights(), q.Machines(), q.LoadMinutes(), now)
+		if wait <= previous {
+			t.Errorf("position %d waits %d minutes, not more than position %d's %d", position, wait, position-1, previous)
+		}
+		previous = wait
+	}
+}
+
+func TestStartTimerRejectsRunningAndCompletedLoads(t *testing.T) {
+	q := NewLaundryQueueWithMachines(2)
+	defer q.Close()
+
+	running, err := q.AddAndStart("Running", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := q.AddAndStart("Done", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.CompleteNow(done.ID)
+
+	if err := q.StartTimer(running.ID, 45); !errors.Is(err, ErrNotWaiting) {
+		t.Errorf("starting a running load: %v, want ErrNotWaiting", err)
+	}
+	if err := q.StartTimer(done.ID, 30); !errors.Is(err, ErrAlreadyCompleted) {
+		t.Errorf("starting a completed load: %v, want ErrAlreadyCompleted", err)
+	}
+	if err := q.StartTimer("missing", 30); !errors.Is(err, ErrNotFound) {
+		t.Errorf("starting a missing item: %v, want ErrNotFound", err)
+	}
+}
+
+func TestStartPathsRefuseWithCanStartReason(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{Allowlist: []string{"Ann", "Bob", "Cat"}})
+	defer q.Close()
+
+	// refused checks that CanStart(who) and a start attempt agree on why
+	refused := func(who string, err error, rule error) {
+		t.Helper()
+		ok, reason := q.CanStart(who)
+		if ok || !errors.Is(err, rule) || err.Error() != reason {
+			t.Errorf("%s: CanStart %v (%q), start error %v, want both refused by %v", who, ok, reason, err, rule)
+		}
+	}
+
+	_, err := q.AddAndStart("Ann, Eve", 30, 1, TierResident)
+	refused("Ann, Eve", err, ErrNotAllowed)
+
+	bob := q.AddToQueue("Bob", 1, TierResident)
+	_, err = q.AddAndStart("Ann", 30, 1, TierResident)
+	refused("Ann", err, ErrOthersWaiting)
+
+	if err := q.StartTimer(bob.ID, 30); err != nil {
+		t.Fatal(err)
+	}
+	cat := q.AddToQueue("Cat", 1, TierResident)
+	refused("Cat", q.StartTimer(cat.ID, 30), ErrNoFreeMachine)
+	refused("Cat", q.StartTimerDelayed(cat.ID, 30, 5), ErrNoFreeMachine)
+
+	eve := q.AddToQueue("Eve", 1, TierResident)
+	refused("Eve", q.StartTimer(eve.ID, 30), ErrNotAllowed)
+
+	if n := len(q.GetAll()); n != 3 {
+		t.Errorf("queue has %d items, want only Bob, Cat and Eve", n)
+	}
+}
+
+func TestRecentlyFreedWindow(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{Machines: 3, TransitTimeout: 10 * time.Minute, DisableAutoRemove: true})
+	defer q.Close()
+
+	for name, ago := range map[string]time.Duration{"Recent": 3 * time.Minute, "Older": 8 * time.Minute, "Stale": 30 * time.Minute} {
+		item, err := q.AddAndStart(name, 30, 1, TierResident)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.CompleteNow(item.ID)
+		backdate(q, item.ID, ago)
+	}
+
+	// A dryer load finishing frees no washer, so it isn't listed
+	dryer, err := q.AddAndStart("Dryer", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backdate(q, dryer.ID, time.Hour)
+	q.CompleteExpiredAndNotify()
+	if !q.StartDrying(dryer.ID, 40) || !q.CompleteNow(dryer.ID) {
+		t.Fatal("could not dry and finish Dryer's load")
+	}
+
+	freed := q.RecentlyFreed(10 * time.Minute)
+	names := make([]string, 0, len(freed))
+	for _, machine := range freed {
+		names = append(names, machine.FreedBy)
+	}
+	if got := strings.Join(names, ","); got != "Recent,Older" {
+		t.Errorf("freed within 10 minutes: %s, want Recent,Older newest first", got)
+	}
+	if len(freed) == 2 && freed[1].MinutesAgo != 8 {
+		t.Errorf("Older freed %d minutes ago, want 8", freed[1].MinutesAgo)
+	}
+}
+
+func TestRemoveByNameRemovesEveryEntry(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	if _, err := q.AddAndStart("Ann", 30, 1, TierResident); err != nil {
+		t.Fatal(err)
+	}
+	q.AddToQueue("ann", 1, TierResident)
+	q.AddToQueue("Bob", 1, TierResident)
+	shared := q.AddToQueue("Ann, Cat", 1, TierResident)
+
+	if removed := q.RemoveByName("ANN"); removed != 3 {
+		t.Errorf("removed %d entries, want 3", removed)
+	}
+	names := make([]string, 0)
+	for _, item := range q.GetAll() {
+		names = append(names, item.Name)
+	}
+	sort.Strings(names)
+	if got := strings.Join(names, ","); got != "Bob,Cat" {
+		t.Errorf("left %s, want Bob and the shared load for Cat", got)
+	}
+	if find(q, shared.ID) == nil {
+		t.Error("shared load was removed instead of keeping its other owner")
+	}
+}
+
+func TestSortedPositionsAreInPositionOrder(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	guest := q.AddToQueue("Guest", 1, TierGuest)
+	resident := q.AddToQueue("Resident", 1, TierResident)
+	staff := q.AddToQueue("Staff", 1, TierStaff)
+
+	positions := SortedPositions(q.GetAll(), q.TierWeights())
+	want := []Position{{staff.ID, 1}, {resident.ID, 2}, {guest.ID, 3}}
+	if len(positions) != len(want) {
+		t.Fatalf("got %d positions, want %d", len(positions), len(want))
+	}
+	for i := range want {
+		if positions[i] != want[i] {
+			t.Errorf("positions[%d] = %+v, want %+v", i, positions[i], want[i])
+		}
+	}
+}
+
+func TestDelayedStartReservesMachineUntilCountdown(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	item := q.AddToQueue("Ann", 1, TierResident)
+	if err := q.StartTimerDelayed(item.ID, 30, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	pending := find(q, item.ID)
+	if !pending.IsPending() || pending.StartsInMinutes() != 5 || pending.GetRemainingMinutes() != 30 {
+		t.Errorf("in the prep window: pending %v, starts in %d, remaining %d; want true, 5, 30",
+			pending.IsPending(), pending.StartsInMinutes(), pending.GetRemainingMinutes())
+	}
+	if free := q.AvailableMachineCount(); free != 0 {
+		t.Errorf("%d machines free during the prep window, want the machine reserved", free)
+	}
+
+	// Five minutes into the countdown, with half a minute to spare so the
+	// rounded-down remaining time is exact
+	backdate(q, item.ID, 10*time.Minute-30*time.Second)
+	started := find(q, item.ID)
+	if started.IsPending() || started.GetRemainingMinutes() != 25 {
+		t.Errorf("after the prep window: pending %v, remaining %d; want false, 25", started.IsPending(), started.GetRemainingMinutes())
+	}
+}
+
+func TestSharedLoadBelongsToEachOwner(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	announced := make(chan Event, 1)
+	unsubscribe := q.Events().Subscribe(func(event Event) { announced <- event }, EventNextUp)
+	defer unsubscribe()
+
+	running, err := q.AddAndStart("Runner", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := q.AddToQueue("Ann, bob", 1, TierResident)
+	q.AddToQueue("Cat", 1, TierResident)
+	q.CompleteNow(running.ID)
+
+	select {
+	case event := <-announced:
+		if event.Item.ID != shared.ID || strings.Join(event.Item.Owners, ",") != "Ann,bob" {
+			t.Errorf("next up %q with owners %v, want the shared load for Ann and bob", event.Item.Name, event.Item.Owners)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shared load was not announced")
+	}
+
+	for _, owner := range []string{"ann", "Bob"} {
+		if !shared.HasOwner(owner) {
+			t.Errorf("shared load isn't owned by %s", owner)
+		}
+		if ok, reason := q.CanStart(owner); !ok {
+			t.Errorf("%s can't start the shared load: %s", owner, reason)
+		}
+	}
+	if ok, _ := q.CanStart("Dan"); ok {
+		t.Error("Dan, who isn't in line, can start ahead of the shared load")
+	}
+}
+
+func TestUpdateItemKeepsPlaceInLine(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{Allowlist: []string{"Ann", "Bob", "Cat", "Dan"}})
+	defer q.Close()
+
+	running, err := q.AddAndStart("Dan", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann := q.AddToQueue("Ann", 1, TierResident)
+	q.AddToQueue("Bob", 1, TierResident)
+
+	if !q.UpdateItem(ann.ID, "Ann, Cat", 3) {
+		t.Fatal("UpdateItem refused a valid change")
+	}
+	updated := find(q, ann.ID)
+	if updated.Name != "Ann, Cat" || updated.NumLoads != 3 || !updated.QueuedAt.Equal(ann.QueuedAt) {
+		t.Errorf("updated %q with %d loads queued at %s, want Ann, Cat with 3 queued at %s",
+			updated.Name, updated.NumLoads, updated.QueuedAt, ann.QueuedAt)
+	}
+	if position := WaitingPositions(q.GetAll(), q.TierWeights())[ann.ID]; position != 1 {
+		t.Errorf("position %d after the update, want 1", position)
+	}
+
+	for _, tt := range []struct {
+		id, name string
+		numLoads int
+	}{
+		{ann.ID, "Ann", 0},
+		{ann.ID, "Ann", MaxNumLoads + 1},
+		{ann.ID, " , ", 1},
+		{ann.ID, "Ann, Mallory", 1},
+		{running.ID, "Dan", 0},
+		{"missing", "Ann", 1},
+	} {
+		if q.UpdateItem(tt.id, tt.name, tt.numLoads) {
+			t.Errorf("UpdateItem(%q, %q, %d) succeeded, want it refused", tt.id, tt.name, tt.numLoads)
+		}
+	}
+	if after := find(q, ann.ID); after.Name != "Ann, Cat" || after.NumLoads != 3 {
+		t.Errorf("refused updates changed the item to %q with %d loads", after.Name, after.NumLoads)
+	}
+}
+
+func TestMoveToPositionLeavesOtherItemsInPlace(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{DisableAutoRemove: true})
+	defer q.Close()
+
+	start := time.Now()
+	q.mu.Lock()
+	q.items = []*QueueItem{
+		{ID: "run", Name: "Runner", Status: StatusInProgress, StartTime: &start, Duration: 30, NumLoads: 1, Tier: TierResident},
+		{ID: "a", Name: "Ann", Status: StatusWaiting, NumLoads: 1, Tier: TierResident},
+		{ID: "done", Name: "Done", Status: StatusCompleted, CompletedAt: &start, NumLoads: 1, Tier: TierResident},
+		{ID: "b", Name: "Bob", Status: StatusWaiting, NumLoads: 1, Tier: TierResident},
+		{ID: "c", Name: "Cat", Status: StatusWaiting, NumLoads: 1, Tier: TierResident},
+	}
+	q.mu.Unlock()
+
+	order := func() string {
+		ids := make([]string, 0)
+		for _, item := range q.GetAll() {
+			ids = append(ids, item.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	if !q.MoveToPosition("c", 1) {
+		t.Fatal("moving Cat to the front was refused")
+	}
+	if got := order(); got != "run,c,done,a,b" {
+		t.Errorf("order after the move %s, want run,c,done,a,b", got)
+	}
+
+	for _, tt := range []struct {
+		id       string
+		position int
+	}{
+		{"a", 0},
+		{"a", 4},
+		{"run", 1},
+		{"done", 1},
+		{"missing", 1},
+	} {
+		if q.MoveToPosition(tt.id, tt.position) {
+			t.Errorf("MoveToPosition(%s, %d) succeeded, want it refused", tt.id, tt.position)
+		}
+	}
+	if got := order(); got != "run,c,done,a,b" {
+		t.Errorf("order after refused moves %s, want run,c,done,a,b", got)
+	}
+}
+
+func TestTwoMachinesFreeTheRightOne(t *testing.T) {
+	q := NewLaundryQueueWithMachines(2)
+	defer q.Close()
+
+	ann, err := q.AddAndStart("Ann", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := q.AddAndStart("Bob", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ann.MachineID != 1 || bob.MachineID != 2 {
+		t.Errorf("machines %d and %d, want 1 and 2", ann.MachineID, bob.MachineID)
+	}
+	if _, err := q.AddAndStart("Cat", 30, 1, TierResident); !errors.Is(err, ErrNoFreeMachine) {
+		t.Fatalf("third start: %v, want ErrNoFreeMachine", err)
+	}
+
+	q.CompleteNow(bob.ID)
+	if free := q.GetFreeMachines(); len(free) != 1 || free[0] != 2 {
+		t.Errorf("free machines %v after Bob finished, want [2]", free)
+	}
+	if n := q.AvailableMachineCount(); n != 1 {
+		t.Errorf("%d machines available, want 1", n)
+	}
+
+	cat, err := q.AddAndStart("Cat", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cat.MachineID != 2 {
+		t.Errorf("Cat started on machine %d, want Bob's machine 2", cat.MachineID)
+	}
+}
+
+func TestCompleteNowCancelsTimer(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	item, err := q.AddAndStart("Ann", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if find(q, item.ID).timer == nil {
+		t.Fatal("running load has no completion timer")
+	}
+	if !q.CompleteNow(item.ID) {
+		t.Fatal("CompleteNow refused a running load")
+	}
+	if completed := find(q, item.ID); completed.Status != StatusCompleted || completed.timer != nil {
+		t.Errorf("after CompleteNow: status %s, timer %v; want completed with no timer", completed.Status, completed.timer)
+	}
+}
+
+// idleFor makes the queue believe its machine has sat free with people waiting for d
+func idleFor(q *LaundryQueue, d time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.idleSince = time.Now().Add(-d)
+}
+
+func TestIdleAlertNeedsWaitingAndThreshold(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{IdleAlertAfter: 5 * time.Minute})
+	defer q.Close()
+
+	alerts := make(chan Event, subscriberBuffer)
+	unsubscribe := q.Events().Subscribe(func(event Event) { alerts <- event }, EventMachineIdle)
+	defer unsubscribe()
+	expect := func(want int, when string) {
+		t.Helper()
+		time.Sleep(50 * time.Millisecond)
+		if got := len(alerts); got != want {
+			t.Errorf("%s: %d idle alerts, want %d", when, got, want)
+		}
+	}
+
+	idleFor(q, time.Hour)
+	q.sweep()
+	expect(0, "idle with nobody waiting")
+
+	q.AddToQueue("Ann", 1, TierResident)
+	q.sweep()
+	idleFor(q, 4*time.Minute)
+	q.sweep()
+	expect(0, "idle under the threshold")
+
+	idleFor(q, 6*time.Minute)
+	q.sweep()
+	q.sweep()
+	expect(1, "idle past the threshold")
+}
+
+func TestPeakWaitingTracksMaximum(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	a := q.AddToQueue("A", 1, TierResident)
+	b := q.AddToQueue("B", 1, TierResident)
+	q.AddToQueue("C", 1, TierResident)
+	q.Remove(a.ID)
+	q.Remove(b.ID)
+	q.AddToQueue("D", 1, TierResident)
+
+	summary := q.Summary()
+	if summary.Waiting != 2 || summary.PeakWaiting != 3 || summary.PeakWaitingToday != 3 {
+		t.Errorf("waiting %d, peak %d, today's peak %d; want 2, 3, 3",
+			summary.Waiting, summary.PeakWaiting, summary.PeakWaitingToday)
+	}
+}
+
+func TestDelayedStartReminderAndCancel(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	reminders := make(chan Event, subscriberBuffer)
+	unsubscribe := q.Events().Subscribe(func(event Event) { reminders <- event }, EventStartingSoon)
+	defer unsubscribe()
+
+	item := q.AddToQueue("Ann", 1, TierResident)
+	if err := q.StartTimerDelayed(item.ID, 30, 1); err != nil {
+		t.Fatal(err)
+	}
+	q.sweep()
+	q.sweep()
+	select {
+	case event := <-reminders:
+		if event.Item.ID != item.ID {
+			t.Errorf("reminder for %q, want Ann", event.Item.Name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no reminder before the delayed start")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(reminders) != 0 {
+		t.Errorf("%d extra reminders", len(reminders))
+	}
+
+	if !q.CancelDelayedStart(item.ID) {
+		t.Fatal("CancelDelayedStart failed before the start")
+	}
+	backdate(q, item.ID, 5*time.Minute)
+	if cancelled := find(q, item.ID); cancelled.Status != StatusWaiting || cancelled.StartTime != nil {
+		t.Errorf("cancelled start is %s with start time %v, want waiting", cancelled.Status, cancelled.StartTime)
+	}
+	if q.AvailableMachineCount() != 1 {
+		t.Error("cancelled start still holds the machine")
+	}
+}
+
+func TestIdleFrontOfLineIsFlaggedAbsent(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{AbsentAfter: 10 * time.Minute, AutoSkipAbsent: true})
+	defer q.Close()
+
+	ann := q.AddToQueue("Ann", 1, TierResident)
+	q.AddToQueue("Bob", 1, TierResident)
+	q.sweep()
+	idleFor(q, 9*time.Minute)
+	q.sweep()
+	if find(q, ann.ID).PossiblyAbsent {
+		t.Fatal("flagged before the threshold")
+	}
+
+	idleFor(q, 11*time.Minute)
+	q.sweep()
+	if !find(q, ann.ID).PossiblyAbsent {
+		t.Fatal("front of the line not flagged after the threshold")
+	}
+	if next := q.NextUp(); next == nil || next.Name != "Bob" {
+		t.Errorf("next up %v, want Bob after skipping Ann", next)
+	}
+}
+
+func TestWaitIfJoinedNow(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{LoadMinutes: 30})
+	defer q.Close()
+
+	if wait := q.WaitIfJoinedNow(); wait != 0 {
+		t.Errorf("empty queue wait %d, want 0", wait)
+	}
+	if _, err := q.AddAndStart("Runner", 40, 1, TierResident); err != nil {
+		t.Fatal(err)
+	}
+	q.AddToQueue("A", 1, TierResident)
+	q.AddToQueue("B", 2, TierResident)
+
+	// 40 minutes left on the machine, then A's 30 and B's 60
+	if wait := q.WaitIfJoinedNow(); wait != 130 {
+		t.Errorf("wait %d, want 130", wait)
+	}
+}
+
+func TestExtremeDurationsAreClamped(t *testing.T) {
+	for _, duration := range []int{math.MinInt, -30, 0, MaxDurationMinutes + 1, math.MaxInt} {
+		q := NewLaundryQueueWithOptions(Options{DisableAutoRemove: true})
+		item, err := q.AddAndStart("Extreme", duration, 1, TierResident)
+		if err != nil {
+			q.Close()
+			t.Fatalf("duration %d: %v", duration, err)
+		}
+		// Short loads complete on their own at once, so read a copy under the lock
+		q.mu.RLock()
+		got := item.snapshot()
+		q.mu.RUnlock()
+		if got.Duration < 0 || got.Duration > MaxDurationMinutes {
+			t.Errorf("duration %d stored as %d", duration, got.Duration)
+		}
+		if remaining := got.GetRemainingMinutes(); remaining < 0 || remaining > MaxDurationMinutes {
+			t.Errorf("duration %d has %d minutes remaining", duration, remaining)
+		}
+		if got.Status == StatusInProgress && got.EndTime().Before(got.StartTime.Add(-time.Second)) {
+			t.Errorf("duration %d ends before it starts", duration)
+		}
+		q.Close()
+	}
+
+	// Durations set without going through the queue are clamped when read
+	past := time.Now().Add(-time.Duration(math.MaxInt64))
+	future := time.Now().Add(time.Duration(math.MaxInt64))
+	tests := []struct {
+		item *QueueItem
+		want int
+	}{
+		{&QueueItem{Status: StatusInProgress, StartTime: &past, Duration: math.MaxInt}, 0},
+		{&QueueItem{Status: StatusInProgress, StartTime: &future, Duration: math.MaxInt}, MaxDurationMinutes},
+		{&QueueItem{Status: StatusInProgress, StartTime: &future, Duration: -5}, 0},
+	}
+	for i, tt := range tests {
+		if got := tt.item.GetRemainingMinutes(); got != tt.want {
+			t.Errorf("item %d has %d minutes remaining, want %d", i, got, tt.want)
+		}
+	}
+}
+
+func TestExtremeDelaysAndShortening(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{Machines: 2, DisableAutoRemove: true})
+	defer q.Close()
+
+	late := q.AddToQueue("Late", 1, TierResident)
+	if err := q.StartTimerDelayed(late.ID, 45, math.MaxInt); err != nil {
+		t.Fatal(err)
+	}
+	if starts := find(q, late.ID).StartsInMinutes(); starts <= 0 || starts > MaxDurationMinutes {
+		t.Errorf("huge delay starts in %d minutes", starts)
+	}
+
+	early := q.AddToQueue("Early", 1, TierResident)
+	if err := q.StartTimerDelayed(early.ID, 45, -60); err != nil {
+		t.Fatal(err)
+	}
+	got := find(q, early.ID)
+	if got.IsPending() {
+		t.Error("negative delay left the start pending")
+	}
+	if remaining := got.GetRemainingMinutes(); remaining < 44 || remaining > 45 {
+		t.Errorf("negative delay has %d minutes remaining, want 45", remaining)
+	}
+
+	if !q.ShortenTimer(early.ID, math.MinInt) {
+		t.Fatal("shortening to a negative time was refused")
+	}
+	if got := find(q, early.ID); got.Status != StatusCompleted || got.GetRemainingMinutes() != 0 {
+		t.Errorf("shortened to a negative time: %s with %d minutes left", got.Status, got.GetRemainingMinutes())
+	}
+	if q.ShortenTimer(late.ID, math.MaxInt) {
+		t.Error("shortening a pending start should be refused")
+	}
+}
+
+func TestStartGapPolicy(t *testing.T) {
+	for _, reject := range []bool{false, true} {
+		q := NewLaundryQueueWithOptions(Options{Machines: 3, StartGap: 10 * time.Minute, RejectBunchedStarts: reject})
+		if _, err := q.AddAndStart("First", 45, 1, TierResident); err != nil {
+			q.Close()
+			t.Fatal(err)
+		}
+		if stagger := q.StaggerMinutes(0); stagger != 10 {
+			t.Errorf("reject %v: suggested delay %d, want 10", reject, stagger)
+		}
+		if stagger := q.StaggerMinutes(4); stagger != 6 {
+			t.Errorf("reject %v: suggested delay with a 4 minute wait %d, want 6", reject, stagger)
+		}
+
+		second := q.AddToQueue("Second", 1, TierResident)
+		err := q.StartTimer(second.ID, 45)
+		if reject && !errors.Is(err, ErrStartTooSoon) {
+			t.Errorf("start within the gap: %v, want ErrStartTooSoon", err)
+		}
+		if !reject && err != nil {
+			t.Errorf("start within the gap without rejecting: %v", err)
+		}
+
+		// A start delayed past the gap is never bunched
+		third := q.AddToQueue("Third", 1, TierResident)
+		if err := q.StartTimerDelayed(third.ID, 45, 10); err != nil {
+			t.Errorf("reject %v: start delayed past the gap: %v", reject, err)
+		}
+		q.Close()
+	}
+}
+
+func TestFutureStartTimeIsNotStarted(t *testing.T) {
+	tests := []struct {
+		ahead    time.Duration
+		startsIn int
+	}{
+		{30 * time.Second, 1},
+		{2 * time.Hour, 120},
+	}
+	for _, tt := range tests {
+		start := time.Now().Add(tt.ahead)
+		item := &QueueItem{Status: StatusInProgress, StartTime: &start, Duration: 45}
+
+		if remaining := item.GetRemainingMinutes(); remaining != 45 {
+			t.Errorf("start %s ahead: %d minutes remaining, want the full 45", tt.ahead, remaining)
+		}
+		if !item.IsPending() {
+			t.Errorf("start %s ahead is not pending", tt.ahead)
+		}
+		if starts := item.StartsInMinutes(); starts != tt.startsIn {
+			t.Errorf("start %s ahead begins in %d minutes, want %d", tt.ahead, starts, tt.startsIn)
+		}
+		if item.IsTimerExpired() {
+			t.Errorf("start %s ahead has expired", tt.ahead)
+		}
+		if !item.holdsMachine() {
+			t.Errorf("start %s ahead does not hold its machine", tt.ahead)
+		}
+		if urgency := item.Urgency(DefaultUrgencySoonMinutes, DefaultUrgencyFinishingMinutes); urgency != UrgencyRunning {
+			t.Errorf("start %s ahead has urgency %q, want %q", tt.ahead, urgency, UrgencyRunning)
+		}
+	}
+
+	// Even a zero-length load hasn't expired before it starts
+	start := time.Now().Add(time.Minute)
+	empty := &QueueItem{Status: StatusInProgress, StartTime: &start}
+	if empty.IsTimerExpired() {
+		t.Error("zero-length load expired before its start")
+	}
+}
+
+func TestRepeatedStartIsIdempotent(t *testing.T) {
+	q := NewLaundryQueueWithMachines(3)
+	defer q.Close()
+
+	started := make(chan Event, subscriberBuffer)
+	unsubscribe := q.Events().Subscribe(func(event Event) { started <- event }, EventTimerStarted)
+	defer unsubscribe()
+
+	ann := q.AddToQueue("Ann", 1, TierResident)
+	for i := 0; i < 2; i++ {
+		if err := q.StartTimer(ann.ID, 45); err != nil {
+			t.Fatalf("start %d: %v", i+1, err)
+		}
+	}
+	if err := q.StartTimer(ann.ID, 30); !errors.Is(err, ErrNotWaiting) {
+		t.Errorf("repeat with another duration: %v, want ErrNotWaiting", err)
+	}
+	if free := q.AvailableMachineCount(); free != 2 {
+		t.Errorf("%d machines free, want 2 after one start", free)
+	}
+
+	// Bob's start marks the end of Ann's events
+	bob := q.AddToQueue("Bob", 1, TierResident)
+	if err := q.StartTimer(bob.ID, 45); err != nil {
+		t.Fatal(err)
+	}
+	starts := 0
+	for done := false; !done; {
+		select {
+		case event := <-started:
+			if event.Item.ID == ann.ID {
+				starts++
+			}
+			done = event.Item.ID == bob.ID
+		case <-time.After(time.Second):
+			t.Fatal("Bob's start was never published")
+		}
+	}
+	if starts != 1 {
+		t.Errorf("Ann's timer started %d times, want 1", starts)
+	}
+}
+
+func TestTotalAddsCountsConcurrentAdds(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	const workers, adds = 50, 20
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < adds; i++ {
+				q.AddToQueue(fmt.Sprintf("Worker %d", w), 1, TierResident)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if total := q.TotalAdds(); total != workers*adds {
+		t.Fatalf("total adds %d, want %d", total, workers*adds)
+	}
+
+	// Removing loads doesn't take back what was added
+	q.RemoveByName("Worker 0")
+	if total := q.Summary().TotalAdds; total != workers*adds {
+		t.Errorf("summary total adds %d after removals, want %d", total, workers*adds)
+	}
+}
+
+func TestPauseAllFreezesAndResumeAllContinues(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{Machines: 2, DisableAutoRemove: true})
+	defer q.Close()
+
+	long, err := q.AddAndStart("Long", 40, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	short, err := q.AddAndStart("Short", 20, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.PauseAll()
+	if !q.IsPaused() {
+		t.Fatal("queue not paused")
+	}
+	frozen := map[string]int{long.ID: find(q, long.ID).GetRemainingMinutes(), short.ID: find(q, short.ID).GetRemainingMinutes()}
+
+	// Half an hour passes during the emergency, longer than Short had left
+	for id := range frozen {
+		backdate(q, id, 30*time.Minute)
+	}
+	q.sweep()
+	for id, want := range frozen {
+		item := find(q, id)
+		if item.Status != StatusInProgress || item.GetRemainingMinutes() != want {
+			t.Errorf("%s while paused: %s with %d minutes left, want %d", item.Name, item.Status, item.GetRemainingMinutes(), want)
+		}
+	}
+
+	q.ResumeAll()
+	if q.IsPaused() {
+		t.Fatal("queue still paused")
+	}
+	for id, want := range frozen {
+		item := find(q, id)
+		if item.PausedAt != nil || item.IsTimerExpired() {
+			t.Errorf("%s did not resume", item.Name)
+		}
+		if remaining := item.GetRemainingMinutes(); remaining < want-1 || remaining > want {
+			t.Errorf("%s resumed with %d minutes left, want %d", item.Name, remaining, want)
+		}
+	}
+}
+
+func TestResumeAllExtendsTransitHold(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{TransitTimeout: 10 * time.Minute, DisableAutoRemove: true})
+	defer q.Close()
+
+	wash, err := q.AddAndStart("Ann", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backdate(q, wash.ID, 31*time.Minute)
+	q.CompleteExpiredAndNotify()
+	if got := find(q, wash.ID).Status; got != StatusTransit {
+		t.Fatalf("finished wash is %s, want in transit", got)
+	}
+
+	// A pause three times the transit timeout must not use up Ann's hold
+	q.PauseAll()
+	backdate(q, wash.ID, 30*time.Minute)
+	q.ResumeAll()
+	q.sweep()
+	item := find(q, wash.ID)
+	if item.Status != StatusTransit || item.PausedAt != nil {
+		t.Fatalf("after resuming: %s, paused at %v; want still in transit", item.Status, item.PausedAt)
+	}
+	if held := time.Since(*item.TransitAt); held > 2*time.Minute {
+		t.Errorf("transit hold counts %s, want the pause left out", held)
+	}
+}
+
+func TestExpiringSoonReturnsOnlyLoadsAboutToGo(t *testing.T) {
+	q := NewLaundryQueueWithMachines(5)
+	defer q.Close()
+
+	ages := map[string]time.Duration{"Fresh": 0, "Middle": 2 * time.Minute, "Older": 4 * time.Minute, "Oldest": 4*time.Minute + 30*time.Second}
+	for name, age := range ages {
+		item, err := q.AddAndStart(name, 30, 1, TierResident)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.CompleteNow(item.ID)
+		backdate(q, item.ID, age)
+	}
+	if _, err := q.AddAndStart("Running", 30, 1, TierResident); err != nil {
+		t.Fatal(err)
+	}
+	q.AddToQueue("Waiting", 1, TierResident)
+
+	var got []string
+	for _, item := range q.ExpiringSoon(2 * time.Minute) {
+		got = append(got, item.Name)
+	}
+	if strings.Join(got, ",") != "Oldest,Older" {
+		t.Errorf("expiring soon %v, want [Oldest Older]", got)
+	}
+
+	kept := NewLaundryQueueWithOptions(Options{DisableAutoRemove: true})
+	defer kept.Close()
+	item, err := kept.AddAndStart("Kept", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept.CompleteNow(item.ID)
+	backdate(kept, item.ID, time.Hour)
+	if expiring := kept.ExpiringSoon(time.Hour); len(expiring) != 0 {
+		t.Errorf("%d loads expiring with auto-remove disabled, want none", len(expiring))
+	}
+}
+
+func TestShortenTimer(t *testing.T) {
+	q := NewLaundryQueueWithOptions(Options{DisableAutoRemove: true})
+	defer q.Close()
+
+	item, err := q.AddAndStart("Ann", 60, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backdate(q, item.ID, 10*time.Minute-30*time.Second)
+
+	if !q.ShortenTimer(item.ID, 15) {
+		t.Fatal("shortening to 15 minutes was refused")
+	}
+	if got := find(q, item.ID); got.GetRemainingMinutes() != 15 || got.Duration != 25 {
+		t.Errorf("after shortening: %d minutes left of %d, want 15 of 25", got.GetRemainingMinutes(), got.Duration)
+	}
+	if q.ShortenTimer(item.ID, 20) {
+		t.Error("lengthening the timer should be refused")
+	}
+
+	waiting := q.AddToQueue("Bob", 1, TierResident)
+	if !q.ShortenTimer(item.ID, 0) {
+		t.Fatal("shortening to 0 was refused")
+	}
+	if got := find(q, item.ID); got.Status != StatusCompleted || got.CompletedAt == nil {
+		t.Errorf("shortened to 0: status %s, want completed", got.Status)
+	}
+	if ok, reason := q.CanStart(waiting.Name); !ok {
+		t.Errorf("machine not freed for Bob: %s", reason)
+	}
+	if q.ShortenTimer(item.ID, 5) {
+		t.Error("shortening a completed load should be refused")
+	}
+}
+
+func TestCloseStopsBackgroundWorker(t *testing.T) {
+	before := runtime.NumGoroutine()
+	queues := make([]*LaundryQueue, 20)
+	for i := range queues {
+		queues[i] = NewLaundryQueue()
+	}
+	if during := runtime.NumGoroutine(); during < before+len(queues) {
+		t.Fatalf("%d goroutines with %d queues open, want at least %d", during, len(queues), before+len(queues))
+	}
+
+	for _, q := range queues {
+		q.Close()
+		select {
+		case <-q.workerDone:
+		default:
+			t.Fatal("worker still running after Close")
+		}
+		// A second Close is a no-op rather than a panic or a hang
+		q.Close()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines after closing, want at most %d", after, before)
+	}
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// NewLaundryQueuePersistent creates a queue that is loaded from and saved to
+// the JSON file at path, so it survives restarts
+func NewLaundryQueuePersistent(path string) *LaundryQueue {
+	return NewLaundryQueueWithOptions(Options{StatePath: path})
+}
+
+// savedQueue is the layout of the state file: the items plus the room-wide
+// state that must survive a restart along with them
+type savedQueue struct {
+	Items          []*QueueItem `json:"items"`
+	Paused         bool         `json:"paused,omitempty"`
+	PeakWaiting    int          `json:"peak_waiting,omitempty"`
+	DayPeakWaiting int          `json:"day_peak_waiting,omitempty"`
+	PeakDay        string       `json:"peak_day,omitempty"`
+	// NextUpNotified and PreStartNotified list the IDs of items already
+	// announced, so a restart doesn't announce them again
+	NextUpNotified   []string `json:"next_up_notified,omitempty"`
+	PreStartNotified []string `json:"pre_start_notified,omitempty"`
+}
+
+// loadState reads saved state from path. A missing file gives an empty
+// queue; an unreadable or corrupt one is logged, moved aside so it isn't
+// overwritten, and also gives an empty queue. Files holding only an item list,
+// from before room-wide state was saved, are still read.
+func loadState(path string) savedQueue {
+	empty := savedQueue{Items: make([]*QueueItem, 0)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Warning: no saved queue at %s, starting empty", path)
+		return empty
+	}
+	if err != nil {
+		log.Printf("Warning: could not read saved queue %s, starting empty: %v", path, err)
+		return empty
+	}
+
+	var state savedQueue
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &state.Items)
+	} else {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil {
+		corrupt := path + ".corrupt"
+		log.Printf("Warning: saved queue %s is corrupt, moving it to %s and starting empty: %v", path, corrupt, err)
+		if err := os.Rename(path, corrupt); err != nil {
+			log.Printf("Warning: could not move corrupt queue aside: %v", err)
+		}
+		return empty
+	}
+
+	nextUpNotified := make(map[string]bool, len(state.NextUpNotified))
+	for _, id := range state.NextUpNotified {
+		nextUpNotified[id] = true
+	}
+	preStartNotified := make(map[string]bool, len(state.PreStartNotified))
+	for _, id := range state.PreStartNotified {
+		preStartNotified[id] = true
+	}
+
+	loaded := make([]*QueueItem, 0, len(state.Items))
+	for _, item := range state.Items {
+		if item == nil || item.ID == "" {
+			continue
+		}
+		item.nextUpNotified = nextUpNotified[item.ID]
+		item.preStartNotified = preStartNotified[item.ID]
+		// A frozen load means the room was paused, even in an older file
+		// that didn't record it
+		if item.PausedAt != nil {
+			state.Paused = true
+		}
+		loaded = append(loaded, item)
+	}
+	state.Items = loaded
+	return state
+}
+
+// stateSnapshot copies the queue into the layout of the state file, sharing
+// nothing with the live items, and numbers it so that of two saves racing
+// to disk, the older one can tell it has been overtaken. Callers must hold
+// the write lock.
+func (q *LaundryQueue) stateSnapshot() (savedQueue, uint64) {
+	state := savedQueue{
+		Items:          make([]*QueueItem, 0, len(q.items)),
+		Paused:         q.paused,
+		PeakWaiting:    q.peakWaiting,
+		DayPeakWaiting: q.dayPeakWaiting,
+		PeakDay:        q.peakDay,
+	}
+	for _, item := range q.items {
+		copied := item.snapshot()
+		state.Items = append(state.Items, &copied)
+		if item.nextUpNotified {
+			state.NextUpNotified = append(state.NextUpNotified, item.ID)
+		}
+		if item.preStartNotified {
+			state.PreStartNotified = append(state.PreStartNotified, item.ID)
+		}
+	}
+	q.saveSeq++
+	return state, q.saveSeq
+}
+
+// save writes a snapshot taken by stateSnapshot to StatePath, logging rather
+// than failing on error so a full disk never blocks the laundry room. It is
+// called without the queue lock; saveMu keeps saves from overlapping, and a
+// snapshot older than the last one written is dropped.
+func (q *LaundryQueue) save(state savedQueue, seq uint64) {
+	q.saveMu.Lock()
+	defer q.saveMu.Unlock()
+
+	if seq <= q.savedSeq {
+		return
+	}
+	if err := saveState(q.opts.StatePath, state); err != nil {
+		log.Printf("Warning: could not save queue to %s: %v", q.opts.StatePath, err)
+		return
+	}
+	q.savedSeq = seq
+}
+
+// saveState atomically replaces the file at path with state, writing to a
+// temporary file in the same directory and renaming it into place
+func saveState(path string, state savedQueue) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRestoredExpiredLoadCompletesIntoHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	start := time.Now().Add(-time.Hour)
+	saved := savedQueue{Items: []*QueueItem{{ID: "a", Name: "Ann", Status: StatusInProgress, StartTime: &start, Duration: 30, NumLoads: 1, Tier: TierResident}}}
+	if err := saveState(path, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewLaundryQueueWithOptions(Options{StatePath: path, TimelineRetention: time.Hour, HistorySize: 5})
+	defer q.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for len(q.GetHistory()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	history := q.GetHistory()
+	if len(history) != 1 || history[0].ID != "a" || history[0].Status != StatusCompleted {
+		t.Fatalf("history = %+v, want the restored load completed", history)
+	}
+}
+
+func TestPauseAndPeaksSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q := NewLaundryQueueWithOptions(Options{StatePath: path})
+	running, err := q.AddAndStart("Ann", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.AddToQueue("Bob", 1, TierResident)
+	q.AddToQueue("Cat", 1, TierResident)
+	q.PauseAll()
+	q.Close()
+
+	restored := NewLaundryQueueWithOptions(Options{StatePath: path})
+	defer restored.Close()
+	if !restored.IsPaused() {
+		t.Fatal("room is not paused after restart")
+	}
+	if summary := restored.Summary(); summary.PeakWaiting != 2 || summary.PeakWaitingToday != 2 {
+		t.Errorf("peaks = %d/%d, want 2/2", summary.PeakWaiting, summary.PeakWaitingToday)
+	}
+
+	restored.ResumeAll()
+	for _, item := range restored.GetAll() {
+		if item.ID == running.ID && (item.PausedAt != nil || item.timer == nil) {
+			t.Errorf("resumed load paused_at = %v, timer = %v; want it running again", item.PausedAt, item.timer)
+		}
+	}
+}
+
+func TestLegacyItemListStateIsRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	legacy := `[{"id": "a", "name": "Ann", "status": "waiting", "num_loads": 1, "tier": "resident"}]`
+	if err := os.WriteFile(path, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := loadState(path)
+	if len(state.Items) != 1 || state.Items[0].Name != "Ann" {
+		t.Fatalf("items = %+v, want Ann", state.Items)
+	}
+}
+
+func TestAnnouncementsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q := NewLaundryQueueWithOptions(Options{StatePath: path})
+	running, err := q.AddAndStart("Runner", 30, 1, TierResident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := q.AddToQueue("Ann", 1, TierResident)
+	q.CompleteNow(running.ID)
+	q.Close()
+
+	restored := NewLaundryQueueWithOptions(Options{StatePath: path})
+	defer restored.Close()
+	announced := make(chan Event, 1)
+	unsubscribe := restored.Events().Subscribe(func(event Event) { announced <- event }, EventNextUp)
+	defer unsubscribe()
+
+	if item := find(restored, next.ID); item == nil || !item.nextUpNotified {
+		t.Fatalf("restored %+v, want Ann already announced", item)
+	}
+	restored.sweep()
+	select {
+	case event := <-announced:
+		t.Errorf("%s announced again after restart", event.Item.Name)
+	default:
+	}
+}
+
+func TestConcurrentChangesAllReachDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q := NewLaundryQueueWithOptions(Options{StatePath: path})
+	defer q.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			q.AddToQueue(fmt.Sprintf("Person %d", i), 1, TierResident)
+		}(i)
+	}
+	wg.Wait()
+
+	if state := loadState(path); len(state.Items) != 20 {
+		t.Errorf("saved %d items, want all 20", len(state.Items))
+	}
+}
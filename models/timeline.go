@@ -0,0 +1,125 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoTimeline is returned by StateAt when the timeline is disabled
+	ErrNoTimeline = errors.New("timeline is disabled")
+	// ErrBeforeTimeline is returned when asked for queue state older than the
+	// timeline retains
+	ErrBeforeTimeline = errors.New("time is before the retained history")
+)
+
+// Timeline records queue events for a bounded window so the queue's state at
+// a past moment can be replayed. Events older than the window are folded into
+// a baseline snapshot rather than kept individually.
+type Timeline struct {
+	mu        sync.Mutex
+	retention time.Duration
+	// since is the start of the window; baseline is the queue state then
+	since    time.Time
+	baseline map[string]QueueItem
+	events   []Event
+}
+
+// NewTimeline creates a timeline that keeps events for the given retention window
+func NewTimeline(retention time.Duration) *Timeline {
+	return &Timeline{
+		retention: retention,
+		since:     time.Now(),
+		baseline:  make(map[string]QueueItem),
+	}
+}
+
+// seed sets the baseline to items, the queue's state when the timeline starts
+func (t *Timeline) seed(items []*QueueItem) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, item := range items {
+		t.baseline[item.ID] = item.snapshot()
+	}
+}
+
+// Since returns the earliest moment the timeline can replay
+func (t *Timeline) Since() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.since
+}
+
+// record appends an event and folds any that have aged out of the window into the baseline
+func (t *Timeline) record(event Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = append(t.events, event)
+
+	cutoff := event.At.Add(-t.retention)
+	if !cutoff.After(t.since) {
+		return
+	}
+	expired := 0
+	for expired < len(t.events) && !t.events[expired].At.After(cutoff) {
+		apply(t.baseline, t.events[expired])
+		expired++
+	}
+	t.since = cutoff
+	if expired == 0 {
+		return
+	}
+
+	// Reslicing keeps the expired events in the backing array until append
+	// next grows it; once the live ones fill less than half of it, copy them
+	// out so the old snapshots can be collected
+	t.events = t.events[expired:]
+	if len(t.events) < cap(t.events)/2 {
+		t.events = append(make([]Event, 0, 2*len(t.events)), t.events...)
+	}
+}
+
+// StateAt reconstructs the items in the queue at the given moment, in the
+// order they were queued
+func (t *Timeline) StateAt(at time.Time) ([]QueueItem, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if at.Before(t.since) {
+		return nil, ErrBeforeTimeline
+	}
+
+	state := make(map[string]QueueItem, len(t.baseline))
+	for id, item := range t.baseline {
+		state[id] = item
+	}
+	for _, event := range t.events {
+		if event.At.After(at) {
+			break
+		}
+		apply(state, event)
+	}
+
+	items := make([]QueueItem, 0, len(state))
+	for _, item := range state {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].QueuedAt.Before(items[j].QueuedAt)
+	})
+	return items, nil
+}
+
+// apply updates state with the item snapshot carried by event
+func apply(state map[string]QueueItem, event Event) {
+	if event.Type == EventItemRemoved {
+		delete(state, event.Item.ID)
+		return
+	}
+	state[event.Item.ID] = event.Item
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimelineReplaysStateAtTime(t *testing.T) {
+	timeline := NewTimeline(time.Hour)
+	t0 := timeline.Since()
+	at := func(m int) time.Time { return t0.Add(time.Duration(m) * time.Minute) }
+	item := func(id, name, status string, queued int) QueueItem {
+		return QueueItem{ID: id, Name: name, Status: status, QueuedAt: at(queued)}
+	}
+
+	for _, event := range []Event{
+		{EventItemAdded, item("1", "Ann", StatusWaiting, 1), at(1)},
+		{EventItemAdded, item("2", "Bob", StatusWaiting, 2), at(2)},
+		{EventTimerStarted, item("1", "Ann", StatusInProgress, 1), at(3)},
+		{EventItemAdded, item("3", "Cat", StatusWaiting, 4), at(4)},
+		{EventItemRemoved, item("2", "Bob", StatusWaiting, 2), at(5)},
+		{EventItemCompleted, item("1", "Ann", StatusCompleted, 1), at(10)},
+	} {
+		timeline.record(event)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want []string
+	}{
+		{t0, nil},
+		{at(3), []string{"Ann in_progress", "Bob waiting"}},
+		{at(4).Add(30 * time.Second), []string{"Ann in_progress", "Bob waiting", "Cat waiting"}},
+		{at(6), []string{"Ann in_progress", "Cat waiting"}},
+		{at(30), []string{"Ann completed", "Cat waiting"}},
+	}
+	for _, tt := range tests {
+		items, err := timeline.StateAt(tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, item := range items {
+			got = append(got, item.Name+" "+item.Status)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("state at %s: %v, want %v", tt.at.Sub(t0), got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("state at %s: %v, want %v", tt.at.Sub(t0), got, tt.want)
+				break
+			}
+		}
+	}
+
+	if _, err := timeline.StateAt(t0.Add(-time.Minute)); !errors.Is(err, ErrBeforeTimeline) {
+		t.Errorf("state before the timeline: %v, want ErrBeforeTimeline", err)
+	}
+}
+
+func TestTimelineFoldsExpiredEvents(t *testing.T) {
+	timeline := NewTimeline(time.Hour)
+	t0 := timeline.Since()
+
+	timeline.record(Event{EventItemAdded, QueueItem{ID: "1", Name: "Ann", Status: StatusWaiting, QueuedAt: t0}, t0.Add(time.Minute)})
+	timeline.record(Event{EventItemAdded, QueueItem{ID: "2", Name: "Bob", Status: StatusWaiting, QueuedAt: t0.Add(2 * time.Hour)}, t0.Add(2 * time.Hour)})
+
+	if since := timeline.Since(); !since.Equal(t0.Add(time.Hour)) {
+		t.Errorf("window starts %s after creation, want 1h", since.Sub(t0))
+	}
+	if _, err := timeline.StateAt(t0.Add(30 * time.Minute)); !errors.Is(err, ErrBeforeTimeline) {
+		t.Errorf("state outside the window: %v, want ErrBeforeTimeline", err)
+	}
+	// Ann's event was folded into the baseline rather than lost
+	items, err := timeline.StateAt(t0.Add(90 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "Ann" {
+		t.Errorf("state after folding %v, want only Ann", items)
+	}
+}
+
+func TestTimelineStaysBoundedAsEventsExpire(t *testing.T) {
+	timeline := NewTimeline(10 * time.Minute)
+	t0 := timeline.Since()
+
+	for i := 0; i < 1000; i++ {
+		at := t0.Add(time.Duration(i) * time.Minute)
+		timeline.record(Event{EventItemUpdated, QueueItem{ID: "1", Name: "Ann", Status: StatusWaiting, QueuedAt: t0}, at})
+	}
+
+	if n := len(timeline.events); n != 10 {
+		t.Errorf("%d events in the window, want 10", n)
+	}
+	if c := cap(timeline.events); c > 40 {
+		t.Errorf("events backing array holds %d, want it to shrink with the window", c)
+	}
+}
+
+func TestStateAtNeedsTimeline(t *testing.T) {
+	q := NewLaundryQueue()
+	defer q.Close()
+
+	if _, err := q.StateAt(time.Now()); !errors.Is(err, ErrNoTimeline) {
+		t.Errorf("StateAt without a timeline: %v, want ErrNoTimeline", err)
+	}
+}